@@ -0,0 +1,147 @@
+// Package clirtest provides helpers for testing a [clir.Runner], like a [clir.Router], in-memory.
+package clirtest
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"maragu.dev/clir"
+)
+
+var update = flag.Bool("clirtest.update", false, "update golden files used by clirtest.Golden")
+
+// Options for [Run].
+type Options struct {
+	Args    []string          // Args without the program name.
+	Stdin   string            // Stdin is the content of [clir.Context.In].
+	Dir     string            // Dir is the [clir.Context.Dir]. Defaults to the working directory of the test.
+	Env     map[string]string // Env is the only environment variables seen by [clir.Context.LookupEnv].
+	Name    string            // Name is the [clir.Context.Name]. Defaults to "app", so help output is the same in all test binaries.
+	Timeout time.Duration     // Timeout for the [clir.Context.Ctx]. Defaults to no timeout.
+}
+
+// Result of [Run].
+type Result struct {
+	Out   string // Out is what was written to [clir.Context.Out].
+	Err   string // Err is what was written to [clir.Context.Err], including the printed error.
+	Code  int    // Code is the exit code, see [clir.ExitCode].
+	Error error  // Error returned from [clir.Runner.Run], if any.
+}
+
+// Run a [clir.Runner] with [clir.RunWithOptions] and the given [Options], capturing output, exit code, and error.
+// No signals are caught, and the process does not exit.
+// It fails the test with the output so far if the runner does not return within a second of the timeout,
+// leaving the runner running, because it cannot be stopped.
+func Run(t *testing.T, r clir.Runner, opts Options) Result {
+	t.Helper()
+
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	}
+	defer cancel()
+
+	args := opts.Args
+	if args == nil {
+		args = []string{}
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = "app"
+	}
+
+	var out, errOut syncBuffer
+	var result Result
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		result.Code = clir.RunWithOptions(r, clir.RunOptions{
+			Args: args,
+			Ctx:  ctx,
+			Dir:  opts.Dir,
+			Env: func(key string) (string, bool) {
+				v, ok := opts.Env[key]
+				return v, ok
+			},
+			Err:  &errOut,
+			In:   strings.NewReader(opts.Stdin),
+			Name: name,
+			Out:  &out,
+			PrintError: func(ctx clir.Context, err error) {
+				result.Error = err
+				ctx.Errorln("Error:", err)
+			},
+			Signals: []os.Signal{},
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			// The runner cannot be stopped, so it's left running, and the output so far is read under the buffer locks
+			t.Fatalf("runner did not return within a second of the timeout of %v\nOut:\n%v\nErr:\n%v", opts.Timeout, out.String(), errOut.String())
+		}
+	}
+
+	result.Out = out.String()
+	result.Err = errOut.String()
+	return result
+}
+
+// syncBuffer is a [bytes.Buffer] safe for concurrent use,
+// because a runner not returning after the timeout can still write to it while it's read.
+type syncBuffer struct {
+	b  bytes.Buffer
+	mu sync.Mutex
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+// Golden compares got with the content of the golden file testdata/<name>.golden, failing the test if they differ.
+// Run the tests with the -clirtest.update flag to write got to the golden file instead.
+func Golden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal("Error creating golden file directory:", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal("Error writing golden file:", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading golden file, run with -clirtest.update to create it: %v", err)
+	}
+
+	if string(want) != got {
+		t.Errorf("Output does not match golden file %v, run with -clirtest.update to update it.\nWant:\n%v\nGot:\n%v", path, string(want), got)
+	}
+}
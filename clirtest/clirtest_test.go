@@ -0,0 +1,115 @@
+package clirtest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/clir"
+	"maragu.dev/clir/clirtest"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("runs a router with args and stdin and captures output", func(t *testing.T) {
+		r := clir.NewRouter()
+		r.RouteFunc("dance", func(ctx clir.Context) error {
+			in, err := io.ReadAll(ctx.In)
+			if err != nil {
+				return err
+			}
+			ctx.Println("Dancing", string(in), ctx.Args)
+			ctx.Errorln("Sweating")
+			return nil
+		})
+
+		result := clirtest.Run(t, r, clirtest.Options{Args: []string{"dance", "salsa"}, Stdin: "fast"})
+		is.Equal(t, "Dancing fast [salsa]\n", result.Out)
+		is.Equal(t, "Sweating\n", result.Err)
+		is.Equal(t, 0, result.Code)
+		is.NotError(t, result.Error)
+	})
+
+	t.Run("uses the name in help, defaulting to app", func(t *testing.T) {
+		r := clir.NewRouter()
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		result := clirtest.Run(t, r, clirtest.Options{Args: []string{"--help"}})
+		is.Equal(t, "Usage:\n  app\n", result.Out)
+
+		result = clirtest.Run(t, r, clirtest.Options{Args: []string{"--help"}, Name: "dance"})
+		is.Equal(t, "Usage:\n  dance\n", result.Out)
+	})
+
+	t.Run("captures the error and exit code", func(t *testing.T) {
+		r := clir.NewRouter()
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return clir.Exit(3, errors.New("oh no"))
+		})
+
+		result := clirtest.Run(t, r, clirtest.Options{})
+		is.Equal(t, "", result.Out)
+		is.Equal(t, "Error: oh no\n", result.Err)
+		is.Equal(t, 3, result.Code)
+		is.Equal(t, "oh no", result.Error.Error())
+	})
+
+	t.Run("captures usage errors from unknown commands", func(t *testing.T) {
+		r := clir.NewRouter()
+		r.RouteFunc("status", func(ctx clir.Context) error {
+			return nil
+		})
+
+		result := clirtest.Run(t, r, clirtest.Options{Args: []string{"stauts"}})
+		is.Equal(t, 2, result.Code)
+		is.Error(t, clir.ErrorRouteNotFound, result.Error)
+	})
+
+	t.Run("uses only the given env", func(t *testing.T) {
+		t.Setenv("CLIRTEST_PARTY", "yes")
+
+		result := clirtest.Run(t, clir.RunnerFunc(func(ctx clir.Context) error {
+			dance, _ := ctx.LookupEnv("CLIRTEST_DANCE")
+			_, ok := ctx.LookupEnv("CLIRTEST_PARTY")
+			ctx.Println(dance, ok)
+			return nil
+		}), clirtest.Options{Env: map[string]string{"CLIRTEST_DANCE": "tango"}})
+		is.Equal(t, "tango false\n", result.Out)
+	})
+
+	t.Run("uses the given working directory", func(t *testing.T) {
+		dir := t.TempDir()
+
+		result := clirtest.Run(t, clir.RunnerFunc(func(ctx clir.Context) error {
+			ctx.Println(ctx.Dir)
+			return nil
+		}), clirtest.Options{Dir: dir})
+		is.Equal(t, dir+"\n", result.Out)
+	})
+
+	t.Run("cancels the context after the timeout", func(t *testing.T) {
+		result := clirtest.Run(t, clir.RunnerFunc(func(ctx clir.Context) error {
+			<-ctx.Ctx.Done()
+			return ctx.Ctx.Err()
+		}), clirtest.Options{Timeout: time.Millisecond})
+		is.Equal(t, 1, result.Code)
+		is.Error(t, context.DeadlineExceeded, result.Error)
+	})
+}
+
+func TestGolden(t *testing.T) {
+	t.Run("passes if the output matches the golden file", func(t *testing.T) {
+		r := clir.NewRouter()
+		r.RouteFunc("dance", func(ctx clir.Context) error {
+			return nil
+		}, clir.WithSummary("Dance like nobody is watching"))
+
+		result := clirtest.Run(t, r, clirtest.Options{Args: []string{"help"}})
+		clirtest.Golden(t, "help", result.Out)
+	})
+}
@@ -0,0 +1,249 @@
+package clir
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Candidate for shell completion.
+type Candidate struct {
+	Value       string
+	Description string
+}
+
+// Completer returns completion [Candidate]-s for the arg being completed, which starts with the prefix.
+// The [Context.Args] are the args before the one being completed.
+// Candidates not starting with the prefix are filtered out afterwards, so completers don't have to.
+type Completer = func(ctx Context, prefix string) []Candidate
+
+// WithCompleter sets [Meta.Completer].
+func WithCompleter(c Completer) RouteOption {
+	return func(m *Meta) {
+		m.Completer = c
+	}
+}
+
+// Shells supported by [CompletionScript].
+var Shells = []string{"bash", "zsh", "fish", "powershell"}
+
+// Completion adds a "completion" branch with a route for each of the [Shells], which prints its completion script
+// for the program named [Context.Name].
+// It also enables the hidden "__complete" route, which the scripts call with the args on the command line to get
+// completion candidates from the running program, one per line, with an optional tab-separated description.
+// The "__complete" route is handled before any middlewares are applied, like help.
+//
+// Completion walks the route tree: literal route patterns and their aliases, branches, and flags and positional
+// arguments from runners satisfying [Describer]. Route pattern segments are completed one at a time, and non-literal
+// segments are skipped, unless the route has a completer from [WithCompleter]. Flag values and positional arguments
+// are completed with [Input.Completer], or [Input.Choices] if there's no completer.
+//
+// Like other routes, it must be added after any calls to [Router.Use].
+func (r *Router) Completion() {
+	r.completion = true
+
+	r.Branch("completion", func(r *Router) {
+		for _, shell := range Shells {
+			r.RouteFunc(shell, func(ctx Context) error {
+				return CompletionScript(ctx.Out, shell, ctx.programName())
+			}, WithSummary("Print the completion script for "+shell+"."))
+		}
+	}, WithSummary("Print a shell completion script."))
+}
+
+// Complete returns completion candidates for the last of the args, which is the one being completed and may be empty.
+func (r *Router) Complete(ctx Context, args []string) []Candidate {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	prefix := args[len(args)-1]
+	ctx.Args = args[:len(args)-1]
+
+	n := r.walk(ctx.Args)
+
+	var candidates []Candidate
+
+	switch {
+	case len(ctx.Args) > 0 && isFlag(ctx.Args[len(ctx.Args)-1]) && takesValue(n.inputs.Flags, ctx.Args[len(ctx.Args)-1]):
+		f, _ := lookupFlag(n.inputs.Flags, ctx.Args[len(ctx.Args)-1])
+		candidates = completeInput(ctx, f, prefix)
+
+	case isFlag(prefix) && strings.Contains(prefix, "="):
+		if f, ok := lookupFlag(n.inputs.Flags, prefix); ok {
+			name, value, _ := strings.Cut(prefix, "=")
+			for _, c := range completeInput(ctx, f, value) {
+				candidates = append(candidates, Candidate{Value: name + "=" + c.Value, Description: c.Description})
+			}
+		}
+
+	case strings.HasPrefix(prefix, "-"):
+		for _, f := range n.inputs.Flags {
+			name := "-" + f.Name
+			if f.Long {
+				name = "-" + name
+				if f.Short != "" {
+					candidates = append(candidates, Candidate{Value: "-" + f.Short, Description: f.Usage})
+				}
+			}
+			candidates = append(candidates, Candidate{Value: name, Description: f.Usage})
+		}
+
+	default:
+		if n.router != nil {
+			candidates = n.router.completeRoutes(ctx, append(slices.Clone(n.prefix), n.args...), prefix)
+		}
+		if len(n.args) < len(n.inputs.Args) {
+			candidates = append(candidates, completeInput(ctx, n.inputs.Args[len(n.args)], prefix)...)
+		}
+	}
+
+	var filtered []Candidate
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c.Value, prefix) && !seen[c.Value] {
+			filtered = append(filtered, c)
+			seen[c.Value] = true
+		}
+	}
+	return filtered
+}
+
+// completeInput with its [Completer], if any, or else its [Input.Choices].
+func completeInput(ctx Context, i Input, prefix string) []Candidate {
+	if i.Completer == nil {
+		var candidates []Candidate
+		for _, choice := range i.Choices {
+			candidates = append(candidates, Candidate{Value: choice})
+		}
+		return candidates
+	}
+	return i.Completer(ctx, prefix)
+}
+
+// completeRoutes returns candidates for the next segment of the routes of the router, including routes in scopes.
+// The words are the args already given after the router, which must match the first segments of a route pattern.
+func (r *Router) completeRoutes(ctx Context, words []string, prefix string) []Candidate {
+	var candidates []Candidate
+	for _, route := range r.routes {
+		if route.scope != nil {
+			candidates = append(candidates, route.scope.completeRoutes(ctx, words, prefix)...)
+			continue
+		}
+
+		if route.meta.Hidden || route.meta.Deprecated != "" {
+			continue
+		}
+
+		for _, p := range route.patterns {
+			segment, ok := p.next(words)
+			if !ok || segment == "" {
+				continue
+			}
+
+			if segment != regexp.QuoteMeta(segment) {
+				if route.meta.Completer != nil {
+					candidates = append(candidates, route.meta.Completer(ctx, prefix)...)
+				}
+				continue
+			}
+
+			candidates = append(candidates, Candidate{Value: segment, Description: route.meta.Summary})
+		}
+	}
+	return candidates
+}
+
+// complete handles the "__complete" route, see [Router.Completion].
+func (r *Router) complete(ctx Context) error {
+	for _, c := range r.Complete(ctx, ctx.Args[1:]) {
+		if c.Description == "" {
+			ctx.Println(c.Value)
+			continue
+		}
+		ctx.Println(c.Value + "\t" + c.Description)
+	}
+	return nil
+}
+
+// CompletionScript for the shell writes a script completing the program with the given name,
+// by calling its hidden "__complete" route. See [Router.Completion].
+func CompletionScript(w io.Writer, shell, name string) error {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	case "powershell":
+		script = powershellCompletion
+	default:
+		return fmt.Errorf("unsupported shell %q, must be one of %v", shell, strings.Join(Shells, ", "))
+	}
+
+	id := regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(name, "_")
+	script = strings.NewReplacer("{{name}}", name, "{{id}}", id).Replace(script)
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+const bashCompletion = `# bash completion for {{name}}
+_{{id}}_completion() {
+	local IFS=$'\n'
+	local out value
+	out=$({{name}} __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null) || return
+	COMPREPLY=()
+	while IFS=$'\t' read -r value _; do
+		[[ -n $value ]] && COMPREPLY+=("$value")
+	done <<< "$out"
+}
+complete -o default -F _{{id}}_completion {{name}}
+`
+
+const zshCompletion = `#compdef {{name}}
+# zsh completion for {{name}}
+_{{id}}() {
+	local -a completions
+	local line value desc
+	for line in "${(@f)$({{name}} __complete "${(@)words[2,$CURRENT]}" 2>/dev/null)}"; do
+		[[ -z $line ]] && continue
+		value=${line%%$'\t'*}
+		desc=${line#*$'\t'}
+		if [[ $desc == $line ]]; then
+			completions+=("${value//:/\\:}")
+		else
+			completions+=("${value//:/\\:}:$desc")
+		fi
+	done
+	if (( ${#completions} )); then
+		_describe 'command' completions
+	else
+		_files
+	fi
+}
+compdef _{{id}} {{name}}
+`
+
+const fishCompletion = `# fish completion for {{name}}
+function __{{id}}_complete
+	set -l args (commandline -opc) (commandline -ct)
+	{{name}} __complete $args[2..-1] 2>/dev/null
+end
+complete -c {{name}} -f -a '(__{{id}}_complete)'
+`
+
+const powershellCompletion = `# PowerShell completion for {{name}}
+Register-ArgumentCompleter -Native -CommandName '{{name}}' -ScriptBlock {
+	param($wordToComplete, $commandAst, $cursorPosition)
+	$words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+	if ($wordToComplete -eq '') { $words += '' }
+	& '{{name}}' __complete @words 2>$null | ForEach-Object {
+		$value, $description = $_ -split "` + "`" + `t", 2
+		if (-not $description) { $description = $value }
+		[System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $description)
+	}
+}
+`
@@ -0,0 +1,205 @@
+package clir_test
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/clir"
+	"maragu.dev/clir/middleware"
+)
+
+func TestRouter_Complete(t *testing.T) {
+	newRouter := func(t *testing.T) *clir.Router {
+		t.Helper()
+
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Bool("v", false, "verbose")
+			fs.String("config", "", "config file")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		r.RouteFunc("status", func(ctx clir.Context) error {
+			return nil
+		}, clir.WithSummary("Show status."), clir.WithAliases("st"))
+
+		r.RouteFunc("secret", func(ctx clir.Context) error {
+			return nil
+		}, clir.WithHidden())
+
+		r.RouteFunc(`\d+`, func(ctx clir.Context) error {
+			return nil
+		})
+
+		r.RouteFunc("db migrate up", func(ctx clir.Context) error {
+			return nil
+		})
+
+		r.RouteFunc("db migrate down", func(ctx clir.Context) error {
+			return nil
+		})
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+				fs.Bool("fancy", false, "dance fancy")
+			}))
+
+			r.RouteFunc("salsa", func(ctx clir.Context) error {
+				return nil
+			})
+
+			r.RouteFunc("samba", func(ctx clir.Context) error {
+				return nil
+			})
+
+			r.RouteFunc(`step-\w+`, func(ctx clir.Context) error {
+				return nil
+			}, clir.WithCompleter(func(ctx clir.Context, prefix string) []clir.Candidate {
+				return []clir.Candidate{{Value: "step-left"}, {Value: "step-right"}}
+			}))
+		})
+
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"completes routes and aliases, but not hidden or non-literal routes", []string{""}, "status,st,db,dance"},
+		{"completes routes with prefix", []string{"s"}, "status,st"},
+		{"completes routes with no args", nil, "status,st,db,dance"},
+		{"completes segments of routes", []string{"db", "migrate", ""}, "up,down"},
+		{"completes segments of routes with prefix", []string{"db", "mi"}, "migrate"},
+		{"completes flags", []string{"-"}, "-config,-v"},
+		{"completes routes after flags", []string{"-v", "-config", "c.json", "d"}, "db,dance"},
+		{"does not complete flag values", []string{"-config", ""}, ""},
+		{"completes routes in branches", []string{"dance", "sa"}, "salsa,samba"},
+		{"completes routes with completers", []string{"dance", "step-r"}, "step-right"},
+		{"completes flags in branches", []string{"dance", "-"}, "-fancy"},
+		{"does not complete routes after leaf routes", []string{"status", ""}, ""},
+		{"does not complete routes after positional args", []string{"foo", ""}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRouter(t)
+
+			var values []string
+			for _, c := range r.Complete(clir.Context{}, test.args) {
+				values = append(values, c.Value)
+			}
+			is.Equal(t, test.expected, strings.Join(values, ","))
+		})
+	}
+	t.Run("completes flag values and positional arguments with choices", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			middleware.Choice(fs, "format", "json", []string{"json", "yaml", "table"}, "output format")
+		}))
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			middleware.ChoiceFold(as, "env", "", []string{"prod", "staging"}, "environment")
+		}))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			return nil
+		})
+
+		for args, expected := range map[string]string{
+			"-format,":       "json,yaml,table",
+			"-format,t":      "table",
+			"-format=y":      "-format=yaml",
+			"":               "prod,staging",
+			"-format,json,s": "staging",
+		} {
+			var values []string
+			for _, c := range r.Complete(clir.Context{}, strings.Split(args, ",")) {
+				values = append(values, c.Value)
+			}
+			is.Equal(t, expected, strings.Join(values, ","))
+		}
+	})
+}
+
+func TestRouter_Completion(t *testing.T) {
+	t.Run("handles the __complete route before middlewares", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(newMiddleware(t, "m1"))
+
+		r.Completion()
+
+		r.RouteFunc("status", func(ctx clir.Context) error {
+			return nil
+		}, clir.WithSummary("Show status."))
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"__complete", ""},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "completion\tPrint a shell completion script.\nstatus\tShow status.\n", b.String())
+
+		b.Reset()
+
+		err = r.Run(clir.Context{
+			Args: []string{"__complete", "completion", "z"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "zsh\tPrint the completion script for zsh.\n", b.String())
+	})
+
+	t.Run("does not handle the __complete route if completion is not added", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		err := r.Run(clir.Context{
+			Args: []string{"__complete", ""},
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+	})
+
+	t.Run("prints completion scripts for all shells", func(t *testing.T) {
+		r := clir.NewRouter()
+		r.Completion()
+
+		for _, shell := range clir.Shells {
+			t.Run(shell, func(t *testing.T) {
+				var b strings.Builder
+				err := r.Run(clir.Context{
+					Args: []string{"completion", shell},
+					Out:  &b,
+				})
+				is.NotError(t, err)
+				is.True(t, strings.Contains(b.String(), " __complete "))
+			})
+		}
+	})
+}
+
+func TestCompletionScript(t *testing.T) {
+	t.Run("errors on unsupported shell", func(t *testing.T) {
+		var b strings.Builder
+		err := clir.CompletionScript(&b, "tcsh", "app")
+		is.True(t, err != nil)
+		is.Equal(t, `unsupported shell "tcsh", must be one of bash, zsh, fish, powershell`, err.Error())
+	})
+
+	t.Run("uses a valid function name for the program name", func(t *testing.T) {
+		var b strings.Builder
+		err := clir.CompletionScript(&b, "bash", "my-app.test")
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "_my_app_test_completion() {"))
+		is.True(t, strings.Contains(b.String(), "complete -o default -F _my_app_test_completion my-app.test\n"))
+	})
+}
@@ -1,5 +1,13 @@
 package clir
 
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
 type Error string
 
 func (e Error) Error() string {
@@ -9,3 +17,230 @@ func (e Error) Error() string {
 const (
 	ErrorRouteNotFound = Error("route not found")
 )
+
+// NotFoundError is returned by [Router.Run] if no route matches the args.
+// It satisfies errors.Is(err, ErrorRouteNotFound).
+type NotFoundError struct {
+	Arg         string   // Arg that didn't match any route. It's empty if the args ran out.
+	Path        []string // Path of args to the branch where matching failed.
+	Suggestions []string // Suggestions of similar literal routes for the arg, most similar first.
+}
+
+// Error satisfies [error].
+// It's like "unknown command 'stauts' for 'db', did you mean 'status'?".
+func (e *NotFoundError) Error() string {
+	var b strings.Builder
+	if e.Arg == "" {
+		b.WriteString(string(ErrorRouteNotFound))
+	} else {
+		b.WriteString("unknown command '" + e.Arg + "'")
+	}
+	if len(e.Path) > 0 {
+		b.WriteString(" for '" + strings.Join(e.Path, " ") + "'")
+	}
+	if len(e.Suggestions) > 0 {
+		b.WriteString(", did you mean '" + strings.Join(e.Suggestions, "' or '") + "'?")
+	}
+	return b.String()
+}
+
+// Is satisfies the interface used by [errors.Is], matching [ErrorRouteNotFound].
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrorRouteNotFound
+}
+
+// ExitCode satisfies [ExitCoder]. It's 2, like for other usage errors.
+func (e *NotFoundError) ExitCode() int {
+	return 2
+}
+
+// ExitCoder is an error with an exit code, which [Run] exits with.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// ExitError wraps an error with an exit code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+// Exit returns an [ExitError] with the exit code and error.
+func Exit(code int, err error) error {
+	return &ExitError{Code: code, Err: err}
+}
+
+// Error satisfies [error].
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit code %v", e.Code)
+	}
+	return e.Err.Error()
+}
+
+// Unwrap for [errors.Is] and [errors.As].
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode satisfies [ExitCoder].
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// UsageError wraps an error from invalid usage, like an unknown flag or an invalid argument.
+type UsageError struct {
+	Err error
+}
+
+// Error satisfies [error].
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap for [errors.Is] and [errors.As].
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode satisfies [ExitCoder]. It's 2, by convention for usage errors.
+func (e *UsageError) ExitCode() int {
+	return 2
+}
+
+// ValidationError lists every problem with the inputs of a [Runner], like missing required flags and invalid values,
+// so they can all be fixed at once. It's a usage error, like [UsageError].
+type ValidationError struct {
+	Problems []error
+}
+
+// Error satisfies [error]. A single problem is just its message, and several problems are listed on separate lines.
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+
+	var b strings.Builder
+	b.WriteString("invalid input:")
+	for _, p := range e.Problems {
+		b.WriteString("\n  - " + p.Error())
+	}
+	return b.String()
+}
+
+// Unwrap for [errors.Is] and [errors.As].
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// ExitCode satisfies [ExitCoder]. It's 2, by convention for usage errors.
+func (e *ValidationError) ExitCode() int {
+	return 2
+}
+
+// ExitCode for the error, which is 0 for no error, the code of the first [ExitCoder] in the error chain, or 1 otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitCoder ExitCoder
+	if errors.As(err, &exitCoder) {
+		return exitCoder.ExitCode()
+	}
+	return 1
+}
+
+// maxSuggestions in a [NotFoundError].
+const maxSuggestions = 3
+
+// notFound returns a [NotFoundError] for the args in the context, which don't match any route.
+func (r *Router) notFound(ctx Context) *NotFoundError {
+	args := ctx.Args
+
+	// Find how many args match the first segments of a route, to report the first one that doesn't
+	var k int
+	for k < len(args) && len(r.nextSegments(args[:k+1])) > 0 {
+		k++
+	}
+
+	err := &NotFoundError{
+		Path: append(slices.Clone(ctx.path), args[:k]...),
+	}
+	if k == len(args) {
+		return err
+	}
+	err.Arg = args[k]
+
+	type suggestion struct {
+		distance int
+		segment  string
+	}
+	var suggestions []suggestion
+	for _, segment := range r.nextSegments(args[:k]) {
+		d := distance(err.Arg, segment)
+		if segment == "" || segment != regexp.QuoteMeta(segment) || (d > 2 && !strings.HasPrefix(segment, err.Arg)) {
+			continue
+		}
+		if slices.ContainsFunc(suggestions, func(s suggestion) bool { return s.segment == segment }) {
+			continue
+		}
+		suggestions = append(suggestions, suggestion{distance: d, segment: segment})
+	}
+
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int {
+		return a.distance - b.distance
+	})
+	for i, s := range suggestions {
+		if i == maxSuggestions {
+			break
+		}
+		err.Suggestions = append(err.Suggestions, s.segment)
+	}
+
+	return err
+}
+
+// nextSegments of non-hidden route patterns after the words, including routes in scopes.
+func (r *Router) nextSegments(words []string) []string {
+	var segments []string
+	for _, route := range r.routes {
+		if route.scope != nil {
+			segments = append(segments, route.scope.nextSegments(words)...)
+			continue
+		}
+
+		if route.meta.Hidden || route.meta.Deprecated != "" {
+			continue
+		}
+
+		for _, p := range route.patterns {
+			if segment, ok := p.next(words); ok {
+				segments = append(segments, segment)
+			}
+		}
+	}
+	return segments
+}
+
+// distance between the strings, as the Levenshtein edit distance.
+func distance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
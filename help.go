@@ -0,0 +1,531 @@
+package clir
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Input describes a flag or positional argument of a [Runner], see [Describer].
+type Input struct {
+	Name      string
+	Type      string // Type of a flag value, like "string" or "duration". Empty for boolean flags.
+	Usage     string
+	Default   string
+	Bool      bool      // Bool is true for boolean flags, which take no value.
+	Env       string    // Env is the name of the environment variable the value is read from, if any.
+	Completer Completer // Completer for the value, used for completion.
+	Long      bool      // Long is true for GNU style flags, with the name used after two dashes, like "--verbose".
+	Short     string    // Short is the one-letter name of a GNU style flag, used after one dash and bundled, like "-v".
+	Required  bool      // Required inputs must be given.
+	Variadic  bool      // Variadic positional arguments take any number of args.
+	Choices   []string  // Choices are the allowed values, if restricted, used for help and completion.
+}
+
+// Synopsis of a positional argument, like "<name>" if required, "[name]" if optional, and "<name>..." if also variadic.
+func (i Input) Synopsis() string {
+	synopsis := "[" + i.Name + "]"
+	if i.Required {
+		synopsis = "<" + i.Name + ">"
+	}
+	if i.Variadic {
+		synopsis += "..."
+	}
+	return synopsis
+}
+
+// FlagNames of the flag as used in args, like "-v" or "-name" for flags from the standard library,
+// and "--verbose" or "-v, --verbose" for GNU style flags.
+func (i Input) FlagNames() string {
+	switch {
+	case !i.Long:
+		return "-" + i.Name
+	case i.Short != "":
+		return "-" + i.Short + ", --" + i.Name
+	default:
+		return "--" + i.Name
+	}
+}
+
+// Inputs of a [Runner], see [Describer].
+type Inputs struct {
+	Args  []Input
+	Flags []Input
+}
+
+// Describer can be satisfied by a [Runner] to describe its [Inputs] for help output and completion.
+// A [Router] checks both the runners of its routes and the runners returned by its [Middleware] for it,
+// so middlewares parsing flags or positional arguments can describe them.
+type Describer interface {
+	Describe() Inputs
+}
+
+// HelpPage is the help for a [Router] branch or route, rendered by a [HelpFunc].
+type HelpPage struct {
+	Name     string   // Name of the program.
+	Path     []string // Path of commands to the branch or route.
+	Meta              // Meta of the route to the branch, or of its root route if that's not set.
+	Commands []HelpCommand
+	Inputs
+	Runnable bool // Runnable is true if the branch or route can run without a command.
+}
+
+// HelpCommand is a command in a [HelpPage].
+type HelpCommand struct {
+	Name    string
+	Summary string
+	Aliases []string
+}
+
+// Usage line for the [HelpPage], like "app post [flags] <command>" or "app cp [flags] <src>... <dst>".
+func (p HelpPage) Usage() string {
+	parts := append([]string{p.Name}, p.Path...)
+	if len(p.Flags) > 0 {
+		parts = append(parts, "[flags]")
+	}
+	for _, a := range p.Args {
+		parts = append(parts, a.Synopsis())
+	}
+	if len(p.Commands) > 0 {
+		if p.Runnable {
+			parts = append(parts, "[command]")
+		} else {
+			parts = append(parts, "<command>")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// HelpFunc renders a [HelpPage], see [Router.Help].
+type HelpFunc = func(ctx Context, p HelpPage) error
+
+// HelpTemplate returns a [HelpFunc] which executes the template with the [HelpPage] as data,
+// writing to [Context.Out].
+func HelpTemplate(t *template.Template) HelpFunc {
+	return func(ctx Context, p HelpPage) error {
+		return t.Execute(ctx.Out, p)
+	}
+}
+
+// DefaultHelp is the [HelpFunc] used if none is set with [Router.Help].
+// It writes the usage line, summary, commands, arguments, and flags to [Context.Out].
+func DefaultHelp(ctx Context, p HelpPage) error {
+	var b strings.Builder
+
+	b.WriteString("Usage:\n  " + p.Usage() + "\n")
+
+	if p.Deprecated != "" {
+		b.WriteString("\nDeprecated: " + p.Deprecated + "\n")
+	}
+
+	if p.Summary != "" {
+		b.WriteString("\n" + p.Summary + "\n")
+	}
+
+	if p.Description != "" {
+		b.WriteString("\n" + p.Description + "\n")
+	}
+
+	if len(p.Aliases) > 0 {
+		b.WriteString("\nAliases:\n  " + strings.Join(p.Aliases, ", ") + "\n")
+	}
+
+	if len(p.Examples) > 0 {
+		b.WriteString("\nExamples:\n")
+		for _, e := range p.Examples {
+			b.WriteString("  " + e + "\n")
+		}
+	}
+
+	if len(p.Commands) > 0 {
+		b.WriteString("\nCommands:\n")
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, c := range p.Commands {
+			_, _ = fmt.Fprintf(w, "  %v\t%v\n", strings.Join(append([]string{c.Name}, c.Aliases...), ", "), c.Summary)
+		}
+		_ = w.Flush()
+	}
+
+	if len(p.Args) > 0 {
+		b.WriteString("\nArguments:\n")
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, a := range p.Args {
+			_, _ = fmt.Fprintf(w, "  %v\t%v\n", a.Name, inputUsage(a))
+		}
+		_ = w.Flush()
+	}
+
+	if len(p.Flags) > 0 {
+		b.WriteString("\nFlags:\n")
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		var hasShort bool
+		for _, f := range p.Flags {
+			hasShort = hasShort || f.Short != ""
+		}
+		for _, f := range p.Flags {
+			name := f.FlagNames()
+			// Align long names of GNU style flags with the long names after short names
+			if hasShort && f.Long && f.Short == "" {
+				name = "    " + name
+			}
+			if f.Type != "" {
+				name += " " + f.Type
+			}
+			_, _ = fmt.Fprintf(w, "  %v\t%v\n", name, inputUsage(f))
+		}
+		_ = w.Flush()
+	}
+
+	_, err := io.WriteString(ctx.Out, b.String())
+	return err
+}
+
+// inputUsage returns the usage of the [Input] with its choices, if any, its default value, if it's not a zero value,
+// its environment variable, if any, and whether it's required.
+func inputUsage(i Input) string {
+	usage := i.Usage
+	if len(i.Choices) > 0 {
+		usage += fmt.Sprintf(" (one of %v)", strings.Join(i.Choices, ", "))
+	}
+	switch i.Default {
+	case "", "0", "false", "[]":
+	default:
+		usage += fmt.Sprintf(" (default %q)", i.Default)
+	}
+	if i.Env != "" {
+		usage += fmt.Sprintf(" [$%v]", i.Env)
+	}
+	if i.Required {
+		usage += " (required)"
+	}
+	return strings.TrimSpace(usage)
+}
+
+// Help sets the [HelpFunc] used to render help for this router and its branches,
+// unless a branch sets its own. If not set, [DefaultHelp] is used.
+//
+// Help is rendered instead of running a route when the args contain "-h", "-help", or "--help" before any "--"
+// and the first positional arg, unless a flag described along the way has that name, like a "-h" flag for a host.
+// It's also rendered when the first arg is "help", no route matches it, and the router takes no positional arguments.
+// The rest of the args select the branch or route to show help for, also by the leading segments of route patterns
+// with several segments, and a [NotFoundError] is returned if they name a command which doesn't exist.
+// Middlewares are not applied when rendering help.
+func (r *Router) Help(fn HelpFunc) {
+	r.help = fn
+}
+
+// helpRequested returns whether help is requested in the args, and the args selecting the branch or route to show help for.
+func (r *Router) helpRequested(args []string) ([]string, bool) {
+	if len(args) > 0 && args[0] == "help" && !r.matches(args) && len(r.inputs().Args) == 0 {
+		return args[1:], true
+	}
+
+	if !slices.ContainsFunc(args, isHelpFlag) {
+		return nil, false
+	}
+
+	// Help flags are skipped by walk when rendering help, so the args can be passed on as they are
+	if n := r.walk(args); n.helpFlag {
+		return args, true
+	}
+
+	return nil, false
+}
+
+// isHelpFlag returns whether the arg is one of the help flags "-h", "-help", and "--help".
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// renderHelp for the branch or route selected by the args.
+// It returns a [NotFoundError] if the args name a command which doesn't exist.
+func (r *Router) renderHelp(ctx Context, args []string) error {
+	n := r.walk(args)
+
+	if n.router != nil && n.router.notFoundRunner == nil && len(n.args) > 0 && len(n.inputs.Args) == 0 {
+		ctx.path = append(slices.Clone(ctx.path), n.path[:len(n.path)-len(n.prefix)]...)
+		ctx.Args = append(slices.Clone(n.prefix), n.args...)
+		return n.router.notFound(ctx)
+	}
+
+	p := HelpPage{
+		Name:   ctx.programName(),
+		Path:   append(slices.Clone(ctx.path), n.path...),
+		Inputs: n.inputs,
+	}
+
+	if n.route != nil {
+		p.Meta = n.route.meta
+	}
+
+	if n.router == nil {
+		p.Runnable = true
+	} else {
+		root, _, _ := n.router.find(nil)
+		if root != nil {
+			p.Runnable = true
+			if n.route == nil {
+				p.Meta = root.meta
+			}
+		}
+		p.Commands = n.router.commands(n.prefix)
+	}
+
+	help := n.help
+	if help == nil {
+		help = ctx.help
+	}
+	if help == nil {
+		help = DefaultHelp
+	}
+	return help(ctx, p)
+}
+
+// node in the route tree, as resolved by [Router.walk].
+type node struct {
+	args     []string // args are the positional args after the path.
+	helpFlag bool     // helpFlag is true if there's a help flag before the positional args, not described by any node.
+	path     []string
+	prefix   []string // prefix of the path matching the leading segments of route patterns in the router, if any.
+	router   *Router  // router is nil if the node is a route which is not a [Router].
+	route    *route   // route to the node, nil for the router walked from.
+	inputs   Inputs
+	help     HelpFunc
+}
+
+// walk the route tree along the args without running anything, and return the node the args point to.
+// Flags described by the nodes along the way are skipped, including their values,
+// also flags of earlier nodes, which can come after the names of branches with interleaved parsing.
+// Routing stops at the first arg not matching a route, which is a positional arg like all args after it.
+func (r *Router) walk(args []string) node {
+	n := node{router: r, inputs: r.inputs(), help: r.help}
+	var parentFlags []Input
+
+	for len(args) > 0 {
+		arg := args[0]
+		args = args[1:]
+
+		if arg == "--" {
+			n.args = append(n.args, args...)
+			break
+		}
+
+		if isFlag(arg) {
+			flags := append(slices.Clone(n.inputs.Flags), parentFlags...)
+			if _, ok := lookupFlag(flags, arg); !ok && isHelpFlag(arg) && len(n.args) == 0 {
+				n.helpFlag = true
+			}
+			if takesValue(flags, arg) && len(args) > 0 {
+				args = args[1:]
+			}
+			continue
+		}
+
+		var route *route
+		var consumed int
+		var scopeInputs Inputs
+		words := append(slices.Clone(n.prefix), arg)
+		if n.router != nil && len(n.args) == 0 {
+			route, consumed, scopeInputs = n.router.find(append(slices.Clone(words), args...))
+			if route == nil && len(n.router.nextSegments(words)) > 0 {
+				// The arg matches the leading segments of a route pattern with several segments, so it's part of the path
+				n.prefix = words
+				n.path = append(n.path, arg)
+				continue
+			}
+		}
+		if route == nil {
+			n.args = append(n.args, arg)
+			continue
+		}
+
+		n.route = route
+		parentFlags = append(parentFlags, n.inputs.Flags...)
+		if consumed == 0 {
+			// The route is a catch-all, so the arg is a positional arg for it
+			n.args = append(n.args, arg)
+		} else {
+			n.path = append(n.path, arg)
+			n.path = append(n.path, args[:consumed-len(words)]...)
+			args = args[consumed-len(words):]
+		}
+		n.prefix = nil
+
+		if router, ok := route.runner.(*Router); ok {
+			n.router = router
+			n.inputs = router.inputs()
+			if router.help != nil {
+				n.help = router.help
+			}
+			continue
+		}
+
+		n.router = nil
+		n.inputs = mergeInputs(scopeInputs, describe(route.runner))
+	}
+
+	return n
+}
+
+// find the route matching the args, descending into scopes,
+// and return it with how many args it consumes and the [Inputs] of the scopes.
+func (r *Router) find(args []string) (*route, int, Inputs) {
+	for _, route := range r.routes {
+		if route.scope != nil {
+			if found, n, inputs := route.scope.find(args); found != nil {
+				return found, n, mergeInputs(route.scope.inputs(), inputs)
+			}
+			continue
+		}
+
+		if _, n, ok := route.match(args); ok {
+			return route, n, Inputs{}
+		}
+	}
+	return nil, 0, Inputs{}
+}
+
+// inputs of the router, as described by its middlewares and the runner of its root route, if any.
+func (r *Router) inputs() Inputs {
+	inputs := r.middlewareInputs()
+	if root, _, scopeInputs := r.find(nil); root != nil {
+		if _, ok := root.runner.(*Router); !ok {
+			inputs = mergeInputs(inputs, scopeInputs, describe(root.runner))
+		}
+	}
+	return inputs
+}
+
+// middlewareInputs described by the middlewares of the router.
+func (r *Router) middlewareInputs() Inputs {
+	var inputs Inputs
+	noop := RunnerFunc(func(ctx Context) error { return nil })
+	for _, m := range r.middlewares {
+		inputs = mergeInputs(inputs, describe(m(noop)))
+	}
+	return inputs
+}
+
+// skipFlags returns the args after the leading flags found in flags, and their values.
+func skipFlags(args []string, flags []Input) []string {
+	for len(args) > 0 && isFlag(args[0]) && knownFlag(flags, args[0]) {
+		if takesValue(flags, args[0]) && len(args) > 1 {
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	return args
+}
+
+// knownFlag returns whether the flag arg is in flags, also as bundled short GNU style flags like "-xvf".
+func knownFlag(flags []Input, arg string) bool {
+	if _, ok := lookupFlag(flags, arg); ok {
+		return true
+	}
+	if strings.HasPrefix(arg, "--") {
+		return false
+	}
+	for _, c := range arg[1:] {
+		f, ok := lookupFlag(flags, "-"+string(c))
+		if !ok || !f.Long {
+			return false
+		}
+		if !f.Bool {
+			// The rest of the arg is the value, if any
+			return true
+		}
+	}
+	return true
+}
+
+// commands of the router, which are all routes except the root route, including routes in scopes.
+// With a prefix, only the routes with patterns starting with it are included, named by the rest of their patterns.
+func (r *Router) commands(prefix []string) []HelpCommand {
+	var commands []HelpCommand
+	for _, route := range r.routes {
+		if route.scope != nil {
+			commands = append(commands, route.scope.commands(prefix)...)
+			continue
+		}
+
+		name := route.name()
+		if len(prefix) > 0 {
+			if _, ok := route.patterns[0].next(prefix); !ok {
+				continue
+			}
+			name = strings.Join(strings.Fields(name)[len(prefix):], " ")
+		}
+		if name == "" || route.meta.Hidden || route.meta.Deprecated != "" {
+			continue
+		}
+		commands = append(commands, HelpCommand{Name: name, Summary: route.meta.Summary, Aliases: route.meta.Aliases})
+	}
+	return commands
+}
+
+// describe the [Inputs] of the runner, if it satisfies [Describer].
+func describe(r Runner) Inputs {
+	if d, ok := r.(Describer); ok {
+		return d.Describe()
+	}
+	return Inputs{}
+}
+
+func mergeInputs(inputs ...Inputs) Inputs {
+	var merged Inputs
+	for _, i := range inputs {
+		merged.Args = append(merged.Args, i.Args...)
+		merged.Flags = append(merged.Flags, i.Flags...)
+	}
+	return merged
+}
+
+// isFlag returns whether the arg looks like a flag, like "-v", "--v", or "-name=value".
+func isFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-'
+}
+
+// lookupFlag by the name in the flag arg, like "-v" or "--name=value",
+// also by the short name and negated like "--no-color" for GNU style flags.
+func lookupFlag(flags []Input, arg string) (Input, bool) {
+	name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+	for _, f := range flags {
+		if f.Name == name || (f.Long && f.Short != "" && f.Short == name) {
+			return f, true
+		}
+	}
+	for _, f := range flags {
+		if f.Long && f.Bool && "no-"+f.Name == name {
+			return f, true
+		}
+	}
+	return Input{}, false
+}
+
+// takesValue returns whether the flag arg is a described non-boolean flag without an inline value,
+// so the next arg is its value. Bundled short GNU style flags like "-xvf" take a value if the last one does.
+func takesValue(flags []Input, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+	if f, ok := lookupFlag(flags, arg); ok {
+		return !f.Bool
+	}
+	if strings.HasPrefix(arg, "--") {
+		return false
+	}
+	for i, c := range arg[1:] {
+		f, ok := lookupFlag(flags, "-"+string(c))
+		if !ok || !f.Long {
+			return false
+		}
+		if !f.Bool {
+			// The rest of the arg is the value, if any
+			return i == len(arg)-2
+		}
+	}
+	return false
+}
@@ -0,0 +1,363 @@
+package clir_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"maragu.dev/is"
+
+	"maragu.dev/clir"
+	"maragu.dev/clir/middleware"
+)
+
+func TestRouter_Help(t *testing.T) {
+	name := filepath.Base(os.Args[0])
+
+	newRouter := func(t *testing.T) *clir.Router {
+		t.Helper()
+
+		r := clir.NewRouter()
+
+		r.Use(newMiddleware(t, "m1"))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			ctx.Println("root")
+			return nil
+		}, clir.WithSummary("Print root."))
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.RouteFunc("salsa", func(ctx clir.Context) error {
+				ctx.Println("salsa")
+				return nil
+			}, clir.WithSummary("Dance salsa."))
+
+			r.RouteFunc("tango", func(ctx clir.Context) error {
+				ctx.Println("tango")
+				return nil
+			})
+		}, clir.WithSummary("Dance something."))
+
+		r.Scope(func(r *clir.Router) {
+			r.RouteFunc("sleep", func(ctx clir.Context) error {
+				ctx.Println("sleep")
+				return nil
+			}, clir.WithSummary("Go to sleep."))
+		})
+
+		return r
+	}
+
+	t.Run("prints help for the root with help flags and does not apply middlewares", func(t *testing.T) {
+		for _, f := range []string{"-h", "-help", "--help"} {
+			t.Run(f, func(t *testing.T) {
+				r := newRouter(t)
+
+				var b strings.Builder
+				err := r.Run(clir.Context{
+					Args: []string{f},
+					Out:  &b,
+				})
+				is.NotError(t, err)
+				is.Equal(t, "Usage:\n  "+name+" [command]\n\nPrint root.\n\nCommands:\n  dance  Dance something.\n  sleep  Go to sleep.\n", b.String())
+			})
+		}
+	})
+
+	t.Run("prints help for a branch", func(t *testing.T) {
+		r := newRouter(t)
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance", "--help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Usage:\n  "+name+" dance <command>\n\nDance something.\n\nCommands:\n  salsa  Dance salsa.\n  tango  \n", b.String())
+	})
+
+	t.Run("prints help for a route with the help command", func(t *testing.T) {
+		r := newRouter(t)
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"help", "dance", "salsa"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Usage:\n  "+name+" dance salsa\n\nDance salsa.\n", b.String())
+	})
+
+	t.Run("prints help for a branch with the help command in the branch", func(t *testing.T) {
+		r := newRouter(t)
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance", "help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		// The root middlewares are applied before routing to the branch, so they run in this case
+		is.True(t, strings.HasPrefix(b.String(), "m1\nUsage:\n  "+name+" dance <command>\n"))
+	})
+
+	t.Run("does not print help if there is a help route", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("help", func(ctx clir.Context) error {
+			ctx.Println("custom help")
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "custom help\n", b.String())
+	})
+
+	t.Run("does not print help for help flags after --", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("dance", func(ctx clir.Context) error {
+			ctx.Println(ctx.Args)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance", "--", "-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "[-- -h]\n", b.String())
+	})
+
+	t.Run("does not print help for help flags defined by flag sets", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.String("h", "", "host")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			host, _ := middleware.Flag[string](ctx, "h")
+			ctx.Println(host)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-h", "example.com"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "example.com\n", b.String())
+	})
+
+	t.Run("does not print help for help flags after positional args", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("dance", func(ctx clir.Context) error {
+			ctx.Println(ctx.Args)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance", "salsa", "-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "[salsa -h]\n", b.String())
+	})
+
+	t.Run("does not print help for the help arg if the router takes positional arguments", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.String("topic", "", "topic to look up")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			topic, _ := middleware.Arg[string](ctx, "topic")
+			ctx.Println(topic)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "help\n", b.String())
+	})
+
+	t.Run("prints route metadata and does not list hidden or deprecated routes", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.RouteFunc("salsa", func(ctx clir.Context) error {
+				return nil
+			})
+
+			r.RouteFunc("secret", func(ctx clir.Context) error {
+				return nil
+			}, clir.WithHidden())
+
+			r.RouteFunc("waltz", func(ctx clir.Context) error {
+				return nil
+			}, clir.WithDeprecated("use salsa instead"))
+		}, clir.WithSummary("Dance something."), clir.WithDescription("Dance all night long."),
+			clir.WithAliases("d"), clir.WithExamples(name+" dance salsa"))
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"help", "d"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Usage:\n  "+name+" d <command>\n\nDance something.\n\nDance all night long.\n\nAliases:\n  d\n\n"+
+			"Examples:\n  "+name+" dance salsa\n\nCommands:\n  salsa  \n", b.String())
+
+		b.Reset()
+
+		err = r.Run(clir.Context{
+			Args: []string{"help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.HasSuffix(b.String(), "\nCommands:\n  dance, d  Dance something.\n"))
+	})
+
+	t.Run("prints help for a route with several segments", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("db migrate up", func(ctx clir.Context) error {
+			return nil
+		}, clir.WithSummary("Migrate up."))
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"db", "migrate", "up", "--help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Usage:\n  "+name+" db migrate up\n\nMigrate up.\n", b.String())
+
+		b.Reset()
+
+		err = r.Run(clir.Context{
+			Args: []string{"help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Usage:\n  "+name+" <command>\n\nCommands:\n  db migrate up  Migrate up.\n", b.String())
+	})
+
+	t.Run("prints help for the leading segments of routes with several segments", func(t *testing.T) {
+		for desc, interleaved := range map[string]bool{"not interleaved": false, "interleaved": true} {
+			t.Run(desc, func(t *testing.T) {
+				r := clir.NewRouter()
+
+				var opts []middleware.Option
+				if interleaved {
+					opts = append(opts, middleware.Interleaved())
+				}
+				r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+					fs.Bool("v", false, "verbose")
+				}, opts...))
+
+				r.Branch("db", func(r *clir.Router) {
+					r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+						fs.String("url", "", "database URL")
+					}))
+
+					r.RouteFunc("migrate up", func(ctx clir.Context) error {
+						return nil
+					}, clir.WithSummary("Migrate up."))
+
+					r.RouteFunc("migrate down", func(ctx clir.Context) error {
+						return nil
+					}, clir.WithSummary("Migrate down."))
+
+					r.RouteFunc("status", func(ctx clir.Context) error {
+						return nil
+					})
+				})
+
+				var b strings.Builder
+				err := r.Run(clir.Context{
+					Args: []string{"db", "migrate", "-h"},
+					Out:  &b,
+					Err:  &b,
+				})
+				is.NotError(t, err)
+				is.Equal(t, "Usage:\n  "+name+" db migrate [flags] <command>\n\nCommands:\n  up    Migrate up.\n  down  Migrate down.\n\n"+
+					"Flags:\n  -url string  database URL\n", b.String())
+			})
+		}
+	})
+
+	t.Run("returns a not found error for help on a command which does not exist", func(t *testing.T) {
+		r := newRouter(t)
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"help", "dnace"},
+			Out:  &b,
+		})
+		var notFoundErr *clir.NotFoundError
+		is.True(t, errors.As(err, &notFoundErr))
+		is.Equal(t, "unknown command 'dnace', did you mean 'dance'?", err.Error())
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.Equal(t, "", b.String())
+
+		err = r.Run(clir.Context{
+			Args: []string{"help", "dance", "waltz"},
+			Out:  &b,
+		})
+		is.True(t, errors.As(err, &notFoundErr))
+		is.Equal(t, "unknown command 'waltz' for 'dance'", err.Error())
+		is.Equal(t, "", b.String())
+	})
+
+	t.Run("can use a custom help func, which is inherited by branches", func(t *testing.T) {
+		r := newRouter(t)
+
+		r.Help(func(ctx clir.Context, p clir.HelpPage) error {
+			ctx.Println(strings.Join(p.Path, " "), len(p.Commands))
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance", "-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "dance 2\n", b.String())
+	})
+
+	t.Run("can use a help template", func(t *testing.T) {
+		r := newRouter(t)
+
+		r.Help(clir.HelpTemplate(template.Must(template.New("").Parse(
+			`{{.Usage}}{{range .Commands}}|{{.Name}}{{end}}`))))
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, name+" [command]|dance|sleep", b.String())
+	})
+}
@@ -27,44 +27,32 @@ func main() {
 	// Add logging middleware to all routes.
 	r.Use(log(l))
 
-	var v *bool
 	r.Use(middleware.Flags(func(fs *flag.FlagSet) {
-		v = fs.Bool("v", false, "verbose")
+		fs.Bool("v", false, "verbose")
 	}))
 
 	// Add a root route which calls printHello.
-	r.Route("", printHello())
+	r.Route("", printHello(), clir.WithSummary("Print a greeting."))
 
 	// Add a named route which calls get.
-	r.Route("get", get(c))
+	r.Route("get", get(c), clir.WithSummary("Get example.com."))
 
-	// Add a greet command with positional arguments
-	r.Branch("greet", func(r *clir.Router) {
-		var name *string
-		var count *int
-
-		r.Use(middleware.Args(func(as *middleware.ArgSet) {
-			name = as.String("name", "World", "name to greet")
-			count = as.Int("count", 1, "number of times to greet")
-		}))
-
-		r.RouteFunc("", func(ctx clir.Context) error {
-			for range *count {
-				ctx.Printfln("Hello, %s!", *name)
-			}
-			return nil
-		})
-	})
+	// Add a greet command, which gets its positional arguments parsed into a greetInput.
+	r.Route("greet", middleware.Handle(greet), clir.WithSummary("Greet someone."))
 
 	// Branch with subcommands
 	r.Branch("post", func(r *clir.Router) {
-		r.Use(ping(c, v))
+		r.Use(ping(c))
+
+		r.Route("stdin", postFromStdin(c), clir.WithSummary("Post stdin to example.com."))
+		r.Route("random", postFromRandom(c), clir.WithSummary("Post a random number to example.com."))
+	}, clir.WithSummary("Post to example.com."))
 
-		r.Route("stdin", postFromStdin(c))
-		r.Route("random", postFromRandom(c))
-	})
+	// Add the "completion" command, to print shell completion scripts, like with "app completion bash".
+	r.Completion()
 
 	// Run the router with a default clir.Context.
+	// Run with -h, --help, or help to see help for any command.
 	clir.Run(r)
 }
 
@@ -91,6 +79,20 @@ func get(c *http.Client) clir.RunnerFunc {
 	}
 }
 
+// greetInput declares the positional arguments of greet with struct tags.
+type greetInput struct {
+	Name  string `arg:"name" default:"World" usage:"name to greet"`
+	Count int    `arg:"count" default:"1" usage:"number of times to greet"`
+}
+
+// greet someone a number of times.
+func greet(ctx clir.Context, in greetInput) error {
+	for range in.Count {
+		ctx.Printfln("Hello, %s!", in.Name)
+	}
+	return nil
+}
+
 // postFromStdin to example.com.
 func postFromStdin(c *http.Client) clir.RunnerFunc {
 	return func(ctx clir.Context) error {
@@ -135,10 +137,10 @@ func log(l *slog.Logger) clir.Middleware {
 }
 
 // ping a URL to check the network.
-func ping(c *http.Client, v *bool) clir.Middleware {
+func ping(c *http.Client) clir.Middleware {
 	return func(next clir.Runner) clir.Runner {
 		return clir.RunnerFunc(func(ctx clir.Context) error {
-			if *v {
+			if v, _ := middleware.Flag[bool](ctx, "v"); v {
 				ctx.Println("Pinging!")
 			}
 			if _, err := c.Get("https://example.com"); err != nil {
@@ -0,0 +1,346 @@
+package middleware
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"maragu.dev/clir"
+)
+
+// Bind middleware binds flags and positional arguments to the fields of a struct of type T, declared with struct tags.
+// On every run, a new T is populated from the args and stored in the [clir.Context], to get with [clir.Value].
+//
+// The struct tags are:
+//   - flag: the flag name, and optionally a one-letter short name, like `flag:"verbose,v"`.
+//   - arg: the positional argument name, like `arg:"env"`. Positional arguments are in field order.
+//   - env: the environment variable bound to the flag, like with [WithEnv].
+//   - default: the default value, parsed like a value from the args.
+//   - usage: the usage for help output.
+//   - required: "true" marks the flag or positional argument as required, like with [Required].
+//   - layout: the layout for [time.Time] fields, [time.RFC3339] by default.
+//   - choices: comma-separated allowed values for string fields, like with [Choice].
+//
+// Fields without a flag or arg tag are ignored. Field types are the ones from [ArgSet] and the value types in this package,
+// like [time.Duration], [url.URL], and map[string]string, as well as types whose pointers satisfy [flag.Value] or
+// [encoding.TextUnmarshaler]. Slice fields for positional arguments are variadic.
+//
+// Flags are parsed like with [Flags], or like with [GNUFlags] if any flag has a short name.
+// The options are applied to the flags and positional arguments. Bind panics if T is not a struct, or the tags are invalid.
+// Invalid values and missing required inputs, for both flags and positional arguments, are reported together in a [clir.ValidationError].
+func Bind[T any](opts ...Option) clir.Middleware {
+	o := newOptions(opts)
+	b := newBinding(reflect.TypeFor[T](), o)
+
+	describeFS, describeAS := b.define(reflect.New(b.typ).Elem())
+
+	return func(next clir.Runner) clir.Runner {
+		return describer{interleaved: o.interleaved, describe: func() clir.Inputs {
+			var inputs clir.Inputs
+			switch fs := describeFS.(type) {
+			case *flag.FlagSet:
+				inputs.Flags = o.flagInputs(fs, nil)
+			case *GNUFlagSet:
+				inputs.Flags = o.flagInputs(fs, fs.Shorthand)
+			}
+			inputs.Args = o.argInputs(describeAS)
+			return inputs
+		}, RunnerFunc: func(ctx clir.Context) error {
+			v := new(T)
+			fs, as := b.define(reflect.ValueOf(v).Elem())
+
+			// Problems with the flags and positional arguments are reported together.
+			var problems []error
+			var err error
+			switch fs := fs.(type) {
+			case *flag.FlagSet:
+				fs.SetOutput(ctx.Err)
+				ctx, problems, err = parseFlags(ctx, o, fs, "-", func(args []string, invalid *[]error) ([]string, error) {
+					return parse(fs, args, o.interleaved, invalid)
+				})
+			case *GNUFlagSet:
+				fs.SetOutput(ctx.Err)
+				ctx, problems, err = parseFlags(ctx, o, fs, "--", func(args []string, invalid *[]error) ([]string, error) {
+					return fs.parse(args, o.interleaved, invalid)
+				})
+			}
+			if err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					return nil
+				}
+				return err
+			}
+
+			if len(as.formal) > 0 || o.noExtraArgs {
+				var argProblems []error
+				ctx, argProblems, err = parseArgs(ctx, as)
+				if err != nil {
+					return err
+				}
+				problems = append(problems, argProblems...)
+			}
+
+			if len(problems) > 0 {
+				return &clir.ValidationError{Problems: problems}
+			}
+
+			return next.Run(clir.WithValue(ctx, *v))
+		}}
+	}
+}
+
+// binding of the fields of a struct type to flags and positional arguments, see [Bind].
+type binding struct {
+	typ    reflect.Type
+	fields []boundField
+	gnu    bool
+	flags  bool
+	o      *options
+}
+
+// boundField is a struct field with its parsed struct tags.
+type boundField struct {
+	index   []int
+	flag    string
+	short   string
+	arg     string
+	def     string
+	usage   string
+	layout  string
+	choices []string
+}
+
+func newBinding(typ reflect.Type, o *options) *binding {
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("cannot bind to %v, which is not a struct", typ))
+	}
+
+	b := &binding{typ: typ, o: o}
+	for _, sf := range reflect.VisibleFields(typ) {
+		flagTag, hasFlag := sf.Tag.Lookup("flag")
+		argTag, hasArg := sf.Tag.Lookup("arg")
+		if !hasFlag && !hasArg {
+			continue
+		}
+		if hasFlag && hasArg {
+			panic(fmt.Sprintf("field %v cannot have both flag and arg tags", sf.Name))
+		}
+		if !sf.IsExported() {
+			panic(fmt.Sprintf("field %v must be exported to be bound", sf.Name))
+		}
+
+		f := boundField{
+			index:  sf.Index,
+			arg:    argTag,
+			def:    sf.Tag.Get("default"),
+			usage:  sf.Tag.Get("usage"),
+			layout: sf.Tag.Get("layout"),
+		}
+		f.flag, f.short, _ = strings.Cut(flagTag, ",")
+		if choices := sf.Tag.Get("choices"); choices != "" {
+			f.choices = strings.Split(choices, ",")
+		}
+		if f.layout == "" {
+			f.layout = time.RFC3339
+		}
+
+		name := f.flag + f.arg
+		if name == "" {
+			panic(fmt.Sprintf("field %v must have a name in its tag", sf.Name))
+		}
+
+		if hasFlag {
+			b.flags = true
+			if len(f.short) > 1 {
+				panic(fmt.Sprintf("short name %q of flag %v must be one letter", f.short, f.flag))
+			}
+			if f.short != "" {
+				b.gnu = true
+			}
+			if env := sf.Tag.Get("env"); env != "" {
+				o.env[f.flag] = env
+			}
+		}
+
+		if required := sf.Tag.Get("required"); required != "" {
+			r, err := strconv.ParseBool(required)
+			if err != nil {
+				panic(fmt.Sprintf("invalid required tag %q on field %v: %v", required, sf.Name, err))
+			}
+			o.required[name] = r
+		}
+
+		b.fields = append(b.fields, f)
+	}
+	return b
+}
+
+// define the flags and positional arguments for the fields of the struct value v.
+// The flag set is nil if there are no flags.
+func (b *binding) define(v reflect.Value) (flagSet, *ArgSet) {
+	var fs flagSet
+	var vs VarSet
+	switch {
+	case !b.flags:
+	case b.gnu:
+		gfs := &GNUFlagSet{}
+		fs, vs = gfs, gfs
+	default:
+		sfs := flag.NewFlagSet("", flag.ContinueOnError)
+		fs, vs = sfs, sfs
+	}
+
+	as := &ArgSet{noExtraArgs: b.o.noExtraArgs, required: b.o.required}
+	for _, f := range b.fields {
+		p := v.FieldByIndex(f.index).Addr().Interface()
+		if f.arg != "" {
+			f.bindArg(as, p)
+			continue
+		}
+		f.bind(shortVarSet{vs: vs, short: f.short}, f.flag, p)
+	}
+	return fs, as
+}
+
+// bindArg binds the field to a positional argument, variadic if the field is a slice.
+func (f boundField) bindArg(as *ArgSet, p any) {
+	switch p := p.(type) {
+	case *[]string:
+		as.StringsVar(p, f.arg, f.usage)
+	case *[]int:
+		as.IntsVar(p, f.arg, f.usage)
+	default:
+		f.bind(as, f.arg, p)
+	}
+}
+
+// bind the field pointed to by p to an input with the given name in the var set, with the default value parsed from its tag.
+func (f boundField) bind(vs VarSet, name string, p any) {
+	var err error
+	switch p := p.(type) {
+	case *string:
+		if f.choices != nil {
+			ChoiceVar(vs, p, name, f.def, f.choices, f.usage)
+			return
+		}
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *string, value string) {
+			vs.Var(newStringValue(value, p), name, f.usage)
+		})
+	case *bool:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *bool, value bool) {
+			vs.Var(newBoolValue(value, p), name, f.usage)
+		})
+	case *int:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *int, value int) {
+			vs.Var(newIntValue(value, p), name, f.usage)
+		})
+	case *float64:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *float64, value float64) {
+			vs.Var(newFloat64Value(value, p), name, f.usage)
+		})
+	case *time.Duration:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *time.Duration, value time.Duration) {
+			DurationVar(vs, p, name, value, f.usage)
+		})
+	case *int64:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *int64, value int64) {
+			Int64Var(vs, p, name, value, f.usage)
+		})
+	case *uint:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *uint, value uint) {
+			UintVar(vs, p, name, value, f.usage)
+		})
+	case *uint64:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *uint64, value uint64) {
+			Uint64Var(vs, p, name, value, f.usage)
+		})
+	case *time.Time:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *time.Time, value time.Time) {
+			TimeVar(vs, p, name, f.layout, value, f.usage)
+		})
+	case *url.URL:
+		URLVar(vs, p, name, f.def, f.usage)
+	case *netip.Addr:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *netip.Addr, value netip.Addr) {
+			AddrVar(vs, p, name, value, f.usage)
+		})
+	case *netip.AddrPort:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *netip.AddrPort, value netip.AddrPort) {
+			AddrPortVar(vs, p, name, value, f.usage)
+		})
+	case *ByteSize:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *ByteSize, value ByteSize) {
+			BytesVar(vs, p, name, value, f.usage)
+		})
+	case *[]string:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *[]string, value []string) {
+			StringSliceVar(vs, p, name, value, f.usage)
+		})
+	case *map[string]string:
+		err = bindVar(vs, p, f.def, func(vs VarSet, p *map[string]string, value map[string]string) {
+			StringMapVar(vs, p, name, value, f.usage)
+		})
+	case flag.Value:
+		if f.def != "" {
+			err = p.Set(f.def)
+		}
+		vs.Var(p, name, f.usage)
+	case encoding.TextUnmarshaler:
+		if f.def != "" {
+			err = p.UnmarshalText([]byte(f.def))
+		}
+		vs.Var(textValue{p: p}, name, f.usage)
+	default:
+		panic(fmt.Sprintf("cannot bind %v to a field of unsupported type %T", name, p))
+	}
+	if err != nil {
+		panic(fmt.Sprintf("invalid default value %q for %v: %v", f.def, name, err))
+	}
+}
+
+// bindVar defines the input with define, with the default value parsed from def by a value defined the same way.
+func bindVar[T any](vs VarSet, p *T, def string, define func(vs VarSet, p *T, value T)) error {
+	var value T
+	if def != "" {
+		var as ArgSet
+		define(&as, new(T), value)
+		if err := as.formal[0].Value.Set(def); err != nil {
+			return err
+		}
+		value = as.formal[0].Value.(flag.Getter).Get().(T)
+	}
+	define(vs, p, value)
+	return nil
+}
+
+// shortVarSet defines inputs with a short name if it's a [GNUFlagSet] and the short name is not empty.
+type shortVarSet struct {
+	vs    VarSet
+	short string
+}
+
+func (s shortVarSet) Var(value flag.Value, name string, usage string) {
+	if fs, ok := s.vs.(*GNUFlagSet); ok && s.short != "" {
+		fs.VarP(value, name, s.short, usage)
+		return
+	}
+	s.vs.Var(value, name, usage)
+}
+
+// Handle adapts a function taking its inputs as a struct of type T to a [clir.Runner].
+// The inputs are declared with struct tags and populated on every run like with [Bind], with the same options,
+// and described for help output and completion with [clir.Describer].
+// The function can be called directly with a T, for example in tests.
+func Handle[T any](fn func(ctx clir.Context, in T) error, opts ...Option) clir.Runner {
+	return Bind[T](opts...)(clir.RunnerFunc(func(ctx clir.Context) error {
+		in, _ := clir.Value[T](ctx)
+		return fn(ctx, in)
+	}))
+}
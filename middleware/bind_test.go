@@ -0,0 +1,307 @@
+package middleware_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/clir"
+	"maragu.dev/clir/middleware"
+)
+
+type deployInput struct {
+	Verbose bool          `flag:"verbose,v" usage:"verbose output"`
+	Region  string        `flag:"region,r" env:"REGION" default:"eu" usage:"deploy region"`
+	Timeout time.Duration `flag:"timeout" default:"30s"`
+	Format  string        `flag:"format" choices:"json,text" default:"text"`
+	Tags    []string      `flag:"tag"`
+	Env     string        `arg:"env" required:"true" usage:"environment"`
+	Hosts   []string      `arg:"hosts"`
+	ignored string
+}
+
+func TestBind(t *testing.T) {
+	t.Run("populates the struct from flags, args, env, and defaults", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Bind[deployInput]())
+
+		var in deployInput
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			in, _ = clir.Value[deployInput](ctx)
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"-v", "--tag", "a,b", "--format=json", "prod", "web1", "web2"},
+			Env:  env(map[string]string{"REGION": "us"}),
+		})
+		is.NotError(t, err)
+		is.True(t, in.Verbose)
+		is.Equal(t, "us", in.Region)
+		is.Equal(t, 30*time.Second, in.Timeout)
+		is.Equal(t, "json", in.Format)
+		is.Equal(t, "a,b", strings.Join(in.Tags, ","))
+		is.Equal(t, "prod", in.Env)
+		is.Equal(t, "web1,web2", strings.Join(in.Hosts, ","))
+	})
+
+	t.Run("populates a new struct on every run", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Bind[deployInput]())
+
+		var in deployInput
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			in, _ = clir.Value[deployInput](ctx)
+			return nil
+		})
+
+		err := r.Run(clir.Context{Args: []string{"-v", "--region", "us", "--tag", "a", "prod", "web1"}})
+		is.NotError(t, err)
+
+		err = r.Run(clir.Context{Args: []string{"staging"}})
+		is.NotError(t, err)
+		is.True(t, !in.Verbose)
+		is.Equal(t, "eu", in.Region)
+		is.Equal(t, 0, len(in.Tags))
+		is.Equal(t, "staging", in.Env)
+		is.Equal(t, 0, len(in.Hosts))
+	})
+
+	t.Run("returns validation errors for invalid and missing inputs", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Bind[deployInput]())
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{Args: []string{"--timeout", "soon"}, Err: io.Discard})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.True(t, strings.Contains(err.Error(), `invalid value "soon" for flag --timeout: time: invalid duration "soon"`))
+
+		err = r.Run(clir.Context{})
+		is.True(t, errors.As(err, &validationErr))
+		is.True(t, strings.HasSuffix(err.Error(), "missing required argument env"))
+	})
+
+	t.Run("reports problems with flags and args together", func(t *testing.T) {
+		type input struct {
+			Region  string        `flag:"region,r" required:"true"`
+			Timeout time.Duration `flag:"timeout"`
+			Env     string        `arg:"env" required:"true"`
+		}
+
+		r := clir.NewRouter()
+
+		var called bool
+		r.Route("deploy", middleware.Handle(func(ctx clir.Context, in input) error {
+			called = true
+			return nil
+		}))
+
+		err := r.Run(clir.Context{Args: []string{"deploy", "--timeout", "soon"}})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.Equal(t, 3, len(validationErr.Problems))
+		is.Equal(t, "invalid input:\n"+
+			"  - invalid value \"soon\" for flag --timeout: time: invalid duration \"soon\"\n"+
+			"  - missing required flag --region\n"+
+			"  - missing required argument env", err.Error())
+		is.True(t, !called)
+	})
+
+	t.Run("uses standard library flags if no flag has a short name", func(t *testing.T) {
+		type input struct {
+			Count int `flag:"count" default:"1"`
+		}
+
+		r := clir.NewRouter()
+
+		r.Use(middleware.Bind[input]())
+
+		var in input
+		r.RouteFunc("", func(ctx clir.Context) error {
+			in, _ = clir.Value[input](ctx)
+			return nil
+		})
+
+		err := r.Run(clir.Context{Args: []string{"-count", "3"}})
+		is.NotError(t, err)
+		is.Equal(t, 3, in.Count)
+
+		err = r.Run(clir.Context{})
+		is.NotError(t, err)
+		is.Equal(t, 1, in.Count)
+	})
+
+	t.Run("applies options", func(t *testing.T) {
+		type input struct {
+			Name string `arg:"name"`
+		}
+
+		r := clir.NewRouter()
+
+		r.Use(middleware.Bind[input](middleware.NoExtraArgs()))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{Args: []string{"a", "b"}})
+		is.True(t, err != nil)
+		is.True(t, strings.HasSuffix(err.Error(), "too many arguments: b"))
+	})
+
+	t.Run("describes the flags and args in help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Bind[deployInput]())
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"--help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "<env> [hosts]..."))
+		is.True(t, strings.Contains(b.String(), "  env    environment (required)\n"))
+		is.True(t, strings.Contains(b.String(), "  -r, --region string     deploy region (default \"eu\") [$REGION]\n"))
+		is.True(t, strings.Contains(b.String(), "  -v, --verbose           verbose output\n"))
+	})
+
+	t.Run("panics on invalid struct tags", func(t *testing.T) {
+		type bothTags struct {
+			Name string `flag:"name" arg:"name"`
+		}
+		type invalidDefault struct {
+			Count int `flag:"count" default:"many"`
+		}
+		type unsupportedType struct {
+			Ch chan int `flag:"ch"`
+		}
+
+		for _, bind := range []func(){
+			func() { middleware.Bind[bothTags]() },
+			func() { middleware.Bind[invalidDefault]() },
+			func() { middleware.Bind[unsupportedType]() },
+			func() { middleware.Bind[string]() },
+		} {
+			func() {
+				defer func() {
+					is.True(t, recover() != nil)
+				}()
+				bind()
+			}()
+		}
+	})
+}
+
+func TestHandle(t *testing.T) {
+	t.Run("calls the function with the parsed inputs", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		var in deployInput
+		r.Route("deploy", middleware.Handle(func(ctx clir.Context, i deployInput) error {
+			in = i
+			return nil
+		}))
+
+		err := r.Run(clir.Context{Args: []string{"deploy", "--region", "us", "prod"}})
+		is.NotError(t, err)
+		is.Equal(t, "us", in.Region)
+		is.Equal(t, "prod", in.Env)
+	})
+
+	t.Run("does not call the function on invalid inputs", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		var called bool
+		r.Route("deploy", middleware.Handle(func(ctx clir.Context, in deployInput) error {
+			called = true
+			return nil
+		}))
+
+		err := r.Run(clir.Context{Args: []string{"deploy"}})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.True(t, !called)
+	})
+
+	t.Run("describes the inputs in help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Route("deploy", middleware.Handle(func(ctx clir.Context, in deployInput) error {
+			return nil
+		}))
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"help", "deploy"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "deploy [flags] <env> [hosts]..."))
+		is.True(t, strings.Contains(b.String(), "  -v, --verbose           verbose output\n"))
+	})
+}
+
+func ExampleHandle() {
+	type greetInput struct {
+		Name string `arg:"name" default:"world" usage:"who to greet"`
+	}
+
+	greet := func(ctx clir.Context, in greetInput) error {
+		ctx.Printfln("Hello, %s!", in.Name)
+		return nil
+	}
+
+	r := clir.NewRouter()
+
+	r.Route("greet", middleware.Handle(greet))
+
+	_ = r.Run(clir.Context{
+		Args: []string{"greet", "you"},
+		Out:  os.Stdout,
+	})
+	// Output: Hello, you!
+}
+
+func ExampleBind() {
+	type greetInput struct {
+		Loud bool   `flag:"loud,l" usage:"greet loudly"`
+		Name string `arg:"name" default:"world" usage:"who to greet"`
+	}
+
+	r := clir.NewRouter()
+
+	r.Use(middleware.Bind[greetInput]())
+
+	r.RouteFunc("*", func(ctx clir.Context) error {
+		in, _ := clir.Value[greetInput](ctx)
+		greeting := "Hello, " + in.Name + "!"
+		if in.Loud {
+			greeting = strings.ToUpper(greeting)
+		}
+		ctx.Println(greeting)
+		return nil
+	})
+
+	_ = r.Run(clir.Context{
+		Args: []string{"-l", "you"},
+		Out:  os.Stdout,
+	})
+	// Output: HELLO, YOU!
+}
@@ -0,0 +1,368 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"maragu.dev/clir"
+)
+
+// Decoder of config files into flag names and values, for [Config].
+type Decoder interface {
+	Decode(r io.Reader) (map[string]string, error)
+}
+
+// DecoderFunc is a function which satisfies [Decoder].
+type DecoderFunc func(r io.Reader) (map[string]string, error)
+
+// Decode satisfies [Decoder].
+func (f DecoderFunc) Decode(r io.Reader) (map[string]string, error) {
+	return f(r)
+}
+
+// JSONDecoder decodes a JSON object of flag names and values.
+// Nested objects are flattened with dots, so {"db": {"url": "x"}} sets the flag "db.url",
+// and arrays are joined with commas.
+var JSONDecoder = DecoderFunc(decodeJSON)
+
+// KeyValueDecoder decodes lines of key=value, like dotenv files and simple TOML files.
+// Empty lines and lines starting with "#" or ";" are skipped, and an "export " prefix is ignored.
+// Values can be quoted with double quotes, which are unquoted like Go strings, or single quotes.
+// Section headers like "[db]" prefix the following keys with the section name and a dot, like "db.url".
+var KeyValueDecoder = DecoderFunc(decodeKeyValue)
+
+// WithConfigFlag reads the config file path from the flag with the given name in the args, for [Config].
+// The flag is removed from the args, and takes precedence over [WithConfigEnv] and [WithConfigXDG].
+// It's taken from the flags before the first positional arg, or before "--" if the flags set right after [Config]
+// are [Interleaved], skipping the values of those flags. It's used with two dashes if they are GNU style flags, like with [GNUFlags].
+func WithConfigFlag(name string) Option {
+	return func(o *options) {
+		o.configFlag = name
+	}
+}
+
+// WithConfigEnv reads the config file path from the environment variable envVar, for [Config].
+// It takes precedence over [WithConfigXDG].
+func WithConfigEnv(envVar string) Option {
+	return func(o *options) {
+		o.configEnv = envVar
+	}
+}
+
+// WithConfigXDG reads the config file at the path relative to the XDG config directory, for [Config],
+// which is $XDG_CONFIG_HOME or $HOME/.config. The file is optional.
+func WithConfigXDG(path string) Option {
+	return func(o *options) {
+		o.configXDG = path
+	}
+}
+
+// WithDecoder sets the [Decoder] for config files with the given extension, like ".json", for [Config].
+func WithDecoder(ext string, d Decoder) Option {
+	return func(o *options) {
+		o.decoders[ext] = d
+	}
+}
+
+// Config middleware loads a config file with values for flags set with [Flags] further down the route.
+// The path of the config file is read from a flag with [WithConfigFlag], an environment variable with [WithConfigEnv],
+// or the XDG config directory with [WithConfigXDG], in that order of precedence.
+// Config files given by flag or environment variable must exist, and relative paths are relative to [clir.Context.Dir].
+//
+// Files are decoded by extension with [JSONDecoder] for ".json", [KeyValueDecoder] for anything else,
+// or a [Decoder] set with [WithDecoder]. Keys not matching a flag are ignored.
+// Values from config files take precedence over defaults, but not over environment variables or args, see [FlagSource].
+func Config(opts ...Option) clir.Middleware {
+	o := newOptions(opts)
+
+	return func(next clir.Runner) clir.Runner {
+		// The config flag sits beside the flags set right after, so it's parsed like them
+		var flags []clir.Input
+		if d, ok := next.(clir.Describer); ok {
+			flags = d.Describe().Flags
+		}
+		gnu := slices.ContainsFunc(flags, func(f clir.Input) bool { return f.Long })
+		interleaved := false
+		if d, ok := next.(describer); ok {
+			interleaved = d.interleaved
+		}
+
+		return describer{describe: func() clir.Inputs {
+			if o.configFlag == "" {
+				return clir.Inputs{}
+			}
+			return clir.Inputs{Flags: []clir.Input{{
+				Name:      o.configFlag,
+				Type:      "path",
+				Usage:     "config file",
+				Env:       o.configEnv,
+				Long:      gnu,
+				Completer: o.completers[o.configFlag],
+			}}}
+		}, RunnerFunc: func(ctx clir.Context) error {
+			path, required, args, err := o.configPath(ctx, flags, gnu, interleaved)
+			if err != nil {
+				return &clir.UsageError{Err: err}
+			}
+			ctx.Args = args
+
+			if path == "" {
+				return next.Run(ctx)
+			}
+
+			path = ctx.Abs(path)
+			values, err := o.loadConfig(path)
+			if err != nil {
+				if !required && errors.Is(err, fs.ErrNotExist) {
+					return next.Run(ctx)
+				}
+				return err
+			}
+
+			return next.Run(clir.WithValue(ctx, config{path: path, values: values}))
+		}}
+	}
+}
+
+// config loaded by [Config], added to the [clir.Context].
+type config struct {
+	path   string
+	values map[string]string
+}
+
+// ConfigFile returns the path of the config file loaded by [Config], if any.
+func ConfigFile(ctx clir.Context) (string, bool) {
+	c, ok := clir.Value[config](ctx)
+	return c.path, ok
+}
+
+// configPath returns the path of the config file, whether it must exist, and the args without the config flag.
+// The config flag is parsed like the flags beside it, which are GNU style or not, and interleaved or not.
+func (o *options) configPath(ctx clir.Context, flags []clir.Input, gnu, interleaved bool) (string, bool, []string, error) {
+	var path string
+	var fromFlag bool
+	args := ctx.Args
+
+	dashes := "-"
+	if gnu {
+		dashes = "--"
+	}
+
+	if o.configFlag != "" {
+		args = nil
+		for i := 0; i < len(ctx.Args); i++ {
+			arg := ctx.Args[i]
+			if arg == "--" || (!interleaved && !isFlag(arg)) {
+				args = append(args, ctx.Args[i:]...)
+				break
+			}
+
+			// The standard flag package accepts one or two dashes, GNU style flags only two for long names
+			name, v, hasValue := strings.Cut(arg, "=")
+			if name != dashes+o.configFlag && (gnu || name != "--"+o.configFlag) {
+				args = append(args, arg)
+				// Skip the value of other flags, so it's not taken for the config flag or a positional arg
+				if isFlag(arg) && takesValue(flags, arg) && i+1 < len(ctx.Args) {
+					i++
+					args = append(args, ctx.Args[i])
+				}
+				continue
+			}
+
+			if !hasValue {
+				if i+1 >= len(ctx.Args) {
+					return "", false, nil, fmt.Errorf("flag needs an argument: %v%v", dashes, o.configFlag)
+				}
+				i++
+				v = ctx.Args[i]
+			}
+			path, fromFlag = v, true
+		}
+	}
+	if fromFlag {
+		return path, true, args, nil
+	}
+
+	if o.configEnv != "" {
+		if v, ok := ctx.LookupEnv(o.configEnv); ok && v != "" {
+			return v, true, args, nil
+		}
+	}
+
+	if o.configXDG != "" {
+		if dir, ok := ctx.LookupEnv("XDG_CONFIG_HOME"); ok && dir != "" {
+			return filepath.Join(dir, o.configXDG), false, args, nil
+		}
+		if home, ok := ctx.LookupEnv("HOME"); ok && home != "" {
+			return filepath.Join(home, ".config", o.configXDG), false, args, nil
+		}
+	}
+
+	return "", false, args, nil
+}
+
+// isFlag returns whether the arg looks like a flag, like "-v", "--v", or "-name=value".
+func isFlag(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-'
+}
+
+// takesValue returns whether the flag arg is a described non-boolean flag without an inline value,
+// so the next arg is its value. Bundled short GNU style flags like "-xvf" take a value if the last one does.
+func takesValue(flags []clir.Input, arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+
+	name := strings.TrimLeft(arg, "-")
+	if i := slices.IndexFunc(flags, func(f clir.Input) bool { return f.Name == name }); i >= 0 {
+		return !flags[i].Bool
+	}
+	if strings.HasPrefix(arg, "--") {
+		return false
+	}
+
+	for j, c := range name {
+		i := slices.IndexFunc(flags, func(f clir.Input) bool { return f.Short == string(c) })
+		if i < 0 {
+			return false
+		}
+		if !flags[i].Bool {
+			// The rest of the arg is the value, if any
+			return j == len(name)-1
+		}
+	}
+	return false
+}
+
+// loadConfig reads and decodes the config file at path.
+func (o *options) loadConfig(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	d, ok := o.decoders[filepath.Ext(path)]
+	if !ok {
+		d = KeyValueDecoder
+	}
+
+	values, err := d.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding config file %v: %w", path, err)
+	}
+	return values, nil
+}
+
+func decodeJSON(r io.Reader) (map[string]string, error) {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+
+	var v map[string]any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	if err := flattenJSON(values, "", v); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flattenJSON adds the values in v to values, with nested object keys joined by dots.
+func flattenJSON(values map[string]string, prefix string, v map[string]any) error {
+	for k, v := range v {
+		key := prefix + k
+		switch v := v.(type) {
+		case nil:
+		case map[string]any:
+			if err := flattenJSON(values, key+".", v); err != nil {
+				return err
+			}
+		case []any:
+			var elems []string
+			for _, e := range v {
+				s, ok := jsonScalar(e)
+				if !ok {
+					return fmt.Errorf("invalid array element for key %v", key)
+				}
+				elems = append(elems, s)
+			}
+			values[key] = strings.Join(elems, ",")
+		default:
+			s, _ := jsonScalar(v)
+			values[key] = s
+		}
+	}
+	return nil
+}
+
+// jsonScalar returns the string value of a JSON string, number, or boolean.
+func jsonScalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+func decodeKeyValue(r io.Reader) (map[string]string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	var prefix string
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			prefix = strings.TrimSpace(line[1:len(line)-1]) + "."
+			if prefix == "." {
+				prefix = ""
+			}
+			continue
+		}
+
+		key, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf("line %v: missing \"=\"", i+1)
+		}
+		key = strings.TrimSpace(key)
+		v = strings.TrimSpace(v)
+
+		switch {
+		case strings.HasPrefix(v, `"`):
+			v, err = strconv.Unquote(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: invalid quoted value: %w", i+1, err)
+			}
+		case strings.HasPrefix(v, "'") && strings.HasSuffix(v, "'") && len(v) > 1:
+			v = v[1 : len(v)-1]
+		default:
+			v, _, _ = strings.Cut(v, " #")
+			v = strings.TrimSpace(v)
+		}
+
+		values[prefix+key] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,350 @@
+package middleware_test
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/clir"
+	"maragu.dev/clir/middleware"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("fills flags from a config file given by flag, with env and args taking precedence", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"name": "Config", "greeting": "Hi", "n": 3, "v": true, "unknown": "x"}`)
+
+		r := clir.NewRouter()
+
+		var name, greeting, color *string
+		var n *int
+		var v *bool
+		r.Use(middleware.Config(middleware.WithConfigFlag("config")))
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			name = fs.String("name", "World", "")
+			greeting = fs.String("greeting", "Hello", "")
+			color = fs.String("color", "red", "")
+			n = fs.Int("n", 0, "")
+			v = fs.Bool("v", false, "")
+		}, middleware.WithEnv("greeting", "GREETING")))
+
+		var sources []string
+		var configFile string
+		r.RouteFunc("", func(ctx clir.Context) error {
+			for _, name := range []string{"name", "greeting", "color", "n"} {
+				source, ok := middleware.FlagSource(ctx, name)
+				is.True(t, ok)
+				sources = append(sources, string(source))
+			}
+			configFile, _ = middleware.ConfigFile(ctx)
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"-config", path, "-n", "5"},
+			Env:  env(map[string]string{"GREETING": "Hey"}),
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Config", *name)
+		is.Equal(t, "Hey", *greeting)
+		is.Equal(t, "red", *color)
+		is.Equal(t, 5, *n)
+		is.True(t, *v)
+		is.Equal(t, "config,env,default,args", strings.Join(sources, ","))
+		is.Equal(t, path, configFile)
+	})
+
+	t.Run("reads the config file path from an env var, and decodes key=value files", func(t *testing.T) {
+		path := writeFile(t, "config", "name = Config\n")
+
+		r := clir.NewRouter()
+
+		var name *string
+		r.Use(middleware.Config(middleware.WithConfigFlag("config"), middleware.WithConfigEnv("MYTOOL_CONFIG")))
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			name = fs.String("name", "World", "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Env: env(map[string]string{"MYTOOL_CONFIG": path}),
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Config", *name)
+	})
+
+	t.Run("reads relative config file paths from the working directory", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"name": "Config"}`)
+
+		r := clir.NewRouter()
+
+		var name *string
+		r.Use(middleware.Config(middleware.WithConfigFlag("config")))
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			name = fs.String("name", "World", "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"-config", "config.json"},
+			Dir:  filepath.Dir(path),
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Config", *name)
+	})
+
+	t.Run("reads an optional config file from the XDG config directory", func(t *testing.T) {
+		path := writeFile(t, filepath.Join("mytool", "config.json"), `{"name": "XDG"}`)
+		dir := filepath.Dir(filepath.Dir(path))
+
+		r := clir.NewRouter()
+
+		var name *string
+		r.Use(middleware.Config(middleware.WithConfigXDG(filepath.Join("mytool", "config.json"))))
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			name = fs.String("name", "World", "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Env: env(map[string]string{"XDG_CONFIG_HOME": dir}),
+		})
+		is.NotError(t, err)
+		is.Equal(t, "XDG", *name)
+
+		err = r.Run(clir.Context{
+			Env: env(map[string]string{"HOME": t.TempDir()}),
+		})
+		is.NotError(t, err)
+	})
+
+	t.Run("errors if a config file given by flag does not exist", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Config(middleware.WithConfigFlag("config")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"-config=" + filepath.Join(t.TempDir(), "config.json")},
+		})
+		is.True(t, errors.Is(err, fs.ErrNotExist))
+	})
+
+	t.Run("returns a usage error on invalid values in the config file", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"n": "notanumber"}`)
+
+		r := clir.NewRouter()
+
+		r.Use(middleware.Config(middleware.WithConfigFlag("config")))
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Int("n", 0, "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"-config", path},
+		})
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.True(t, strings.Contains(err.Error(), `invalid value "notanumber" for flag -n from config file`))
+	})
+
+	t.Run("can use a custom decoder", func(t *testing.T) {
+		path := writeFile(t, "config.custom", "Custom")
+
+		r := clir.NewRouter()
+
+		var name *string
+		r.Use(middleware.Config(middleware.WithConfigFlag("config"), middleware.WithDecoder(".custom", middleware.DecoderFunc(func(r io.Reader) (map[string]string, error) {
+			b, err := io.ReadAll(r)
+			return map[string]string{"name": string(b)}, err
+		}))))
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			name = fs.String("name", "World", "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"-config", path},
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Custom", *name)
+	})
+
+	t.Run("only takes the config flag from the flags before positional args, and not as the value of another flag", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"name": "Config"}`)
+
+		for desc, interleaved := range map[string]bool{"not interleaved": false, "interleaved": true} {
+			t.Run(desc, func(t *testing.T) {
+				r := clir.NewRouter()
+
+				var opts []middleware.Option
+				if interleaved {
+					opts = append(opts, middleware.Interleaved())
+				}
+				var name, greeting *string
+				r.Use(middleware.Config(middleware.WithConfigFlag("config")))
+				r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+					name = fs.String("name", "World", "")
+					greeting = fs.String("greeting", "Hello", "")
+				}, opts...))
+
+				var args []string
+				r.RouteFunc("*", func(ctx clir.Context) error {
+					args = ctx.Args
+					return nil
+				})
+
+				err := r.Run(clir.Context{
+					Args: []string{"-greeting", "-config", "a", "--", "-config", path},
+				})
+				is.NotError(t, err)
+				is.Equal(t, "World", *name)
+				is.Equal(t, "-config", *greeting)
+				is.Equal(t, "a -- -config "+path, strings.Join(args, " "))
+			})
+		}
+
+		r := clir.NewRouter()
+
+		r.Use(middleware.Config(middleware.WithConfigFlag("config")))
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.String("name", "World", "")
+		}))
+
+		var args []string
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			args = ctx.Args
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"a", "-config", path},
+		})
+		is.NotError(t, err)
+		is.Equal(t, "a -config "+path, strings.Join(args, " "))
+	})
+
+	t.Run("takes the config flag with two dashes next to GNU style flags", func(t *testing.T) {
+		path := writeFile(t, "config.json", `{"name": "Config"}`)
+
+		r := clir.NewRouter()
+
+		var name *string
+		r.Use(middleware.Config(middleware.WithConfigFlag("config")))
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			name = fs.String("name", "World", "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"--config", path},
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Config", *name)
+
+		err = r.Run(clir.Context{
+			Args: []string{"--config"},
+		})
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.True(t, strings.HasSuffix(err.Error(), "flag needs an argument: --config"))
+	})
+
+	t.Run("describes the config flag in router help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Config(middleware.WithConfigFlag("config"), middleware.WithConfigEnv("MYTOOL_CONFIG")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "\nFlags:\n  -config path  config file [$MYTOOL_CONFIG]\n"))
+	})
+}
+
+func TestJSONDecoder(t *testing.T) {
+	t.Run("flattens nested objects and joins arrays", func(t *testing.T) {
+		values, err := middleware.JSONDecoder.Decode(strings.NewReader(`{"a": "x", "b": 1.5, "c": false, "d": null, "db": {"url": "y"}, "e": ["1", 2]}`))
+		is.NotError(t, err)
+		is.Equal(t, 5, len(values))
+		is.Equal(t, "x", values["a"])
+		is.Equal(t, "1.5", values["b"])
+		is.Equal(t, "false", values["c"])
+		is.Equal(t, "y", values["db.url"])
+		is.Equal(t, "1,2", values["e"])
+	})
+}
+
+func TestKeyValueDecoder(t *testing.T) {
+	t.Run("decodes dotenv and simple TOML files", func(t *testing.T) {
+		values, err := middleware.KeyValueDecoder.Decode(strings.NewReader(`# comment
+a=x
+export b = "y\tz"
+c = 'w' 
+d = v # comment
+
+[db]
+url = "postgres://"
+`))
+		is.NotError(t, err)
+		is.Equal(t, 5, len(values))
+		is.Equal(t, "x", values["a"])
+		is.Equal(t, "y\tz", values["b"])
+		is.Equal(t, "w", values["c"])
+		is.Equal(t, "v", values["d"])
+		is.Equal(t, "postgres://", values["db.url"])
+	})
+
+	t.Run("errors on lines without equal sign", func(t *testing.T) {
+		_, err := middleware.KeyValueDecoder.Decode(strings.NewReader("a=x\nb\n"))
+		is.True(t, err != nil)
+		is.Equal(t, `line 2: missing "="`, err.Error())
+	})
+}
+
+// writeFile with the content to the path in a temporary directory, and return the full path.
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+
+	path = filepath.Join(t.TempDir(), path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,436 @@
+package middleware
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"maragu.dev/clir"
+)
+
+// GNUFlagSet is like [flag.FlagSet], but for POSIX and GNU style flags:
+//   - Long flags are used with two dashes, like "--verbose", "--output file", or "--output=file"
+//   - Short flags are used with one dash, like "-v", "-o file", or "-ofile", and can be bundled, like "-xvf file"
+//   - Boolean flags can be negated with a "no-" prefix, like "--no-color"
+//   - A "--" stops parsing
+//
+// Flags have a long name and optionally a short name, and values are [flag.Value]-s, like with [flag.FlagSet].
+// The zero value is ready to use. Errors from parsing are printed with the usage to the output, like with [flag.ContinueOnError].
+type GNUFlagSet struct {
+	Usage func()
+
+	actual     map[string]*flag.Flag
+	args       []string
+	formal     map[string]*flag.Flag
+	invalid    *[]error // invalid values while parsing, see [GNUFlagSet.parse].
+	output     io.Writer
+	parsed     bool
+	shorthands map[string]string // shorthands maps short names to long names.
+}
+
+// Var defines a flag with the given long name and usage.
+func (f *GNUFlagSet) Var(value flag.Value, name string, usage string) {
+	f.VarP(value, name, "", usage)
+}
+
+// VarP is like [GNUFlagSet.Var], but with a one-letter short name, which may be empty.
+// Like [flag.FlagSet.Var], it panics if a flag with the same long or short name exists.
+func (f *GNUFlagSet) VarP(value flag.Value, name, short string, usage string) {
+	if f.formal == nil {
+		f.formal = map[string]*flag.Flag{}
+		f.shorthands = map[string]string{}
+	}
+	if _, ok := f.formal[name]; ok {
+		panic(fmt.Sprintf("flag redefined: %v", name))
+	}
+	if len(short) > 1 {
+		panic(fmt.Sprintf("flag shorthand must be one letter: %v", short))
+	}
+	if _, ok := f.shorthands[short]; ok && short != "" {
+		panic(fmt.Sprintf("flag shorthand redefined: %v", short))
+	}
+
+	f.formal[name] = &flag.Flag{Name: name, Usage: usage, Value: value, DefValue: value.String()}
+	if short != "" {
+		f.shorthands[short] = name
+	}
+}
+
+// String defines a string flag with the given long name, default value, and usage.
+func (f *GNUFlagSet) String(name string, value string, usage string) *string {
+	return f.StringP(name, "", value, usage)
+}
+
+// StringP is like [GNUFlagSet.String], but with a short name.
+func (f *GNUFlagSet) StringP(name, short string, value string, usage string) *string {
+	p := new(string)
+	f.StringVarP(p, name, short, value, usage)
+	return p
+}
+
+// StringVar defines a string flag with a pointer.
+func (f *GNUFlagSet) StringVar(p *string, name string, value string, usage string) {
+	f.StringVarP(p, name, "", value, usage)
+}
+
+// StringVarP is like [GNUFlagSet.StringVar], but with a short name.
+func (f *GNUFlagSet) StringVarP(p *string, name, short string, value string, usage string) {
+	f.VarP(newStringValue(value, p), name, short, usage)
+}
+
+// Int defines an int flag with the given long name, default value, and usage.
+func (f *GNUFlagSet) Int(name string, value int, usage string) *int {
+	return f.IntP(name, "", value, usage)
+}
+
+// IntP is like [GNUFlagSet.Int], but with a short name.
+func (f *GNUFlagSet) IntP(name, short string, value int, usage string) *int {
+	p := new(int)
+	f.IntVarP(p, name, short, value, usage)
+	return p
+}
+
+// IntVar defines an int flag with a pointer.
+func (f *GNUFlagSet) IntVar(p *int, name string, value int, usage string) {
+	f.IntVarP(p, name, "", value, usage)
+}
+
+// IntVarP is like [GNUFlagSet.IntVar], but with a short name.
+func (f *GNUFlagSet) IntVarP(p *int, name, short string, value int, usage string) {
+	f.VarP(newIntValue(value, p), name, short, usage)
+}
+
+// Bool defines a bool flag with the given long name, default value, and usage.
+func (f *GNUFlagSet) Bool(name string, value bool, usage string) *bool {
+	return f.BoolP(name, "", value, usage)
+}
+
+// BoolP is like [GNUFlagSet.Bool], but with a short name.
+func (f *GNUFlagSet) BoolP(name, short string, value bool, usage string) *bool {
+	p := new(bool)
+	f.BoolVarP(p, name, short, value, usage)
+	return p
+}
+
+// BoolVar defines a bool flag with a pointer.
+func (f *GNUFlagSet) BoolVar(p *bool, name string, value bool, usage string) {
+	f.BoolVarP(p, name, "", value, usage)
+}
+
+// BoolVarP is like [GNUFlagSet.BoolVar], but with a short name.
+func (f *GNUFlagSet) BoolVarP(p *bool, name, short string, value bool, usage string) {
+	f.VarP(newBoolValue(value, p), name, short, usage)
+}
+
+// Float64 defines a float64 flag with the given long name, default value, and usage.
+func (f *GNUFlagSet) Float64(name string, value float64, usage string) *float64 {
+	return f.Float64P(name, "", value, usage)
+}
+
+// Float64P is like [GNUFlagSet.Float64], but with a short name.
+func (f *GNUFlagSet) Float64P(name, short string, value float64, usage string) *float64 {
+	p := new(float64)
+	f.Float64VarP(p, name, short, value, usage)
+	return p
+}
+
+// Float64Var defines a float64 flag with a pointer.
+func (f *GNUFlagSet) Float64Var(p *float64, name string, value float64, usage string) {
+	f.Float64VarP(p, name, "", value, usage)
+}
+
+// Float64VarP is like [GNUFlagSet.Float64Var], but with a short name.
+func (f *GNUFlagSet) Float64VarP(p *float64, name, short string, value float64, usage string) {
+	f.VarP(newFloat64Value(value, p), name, short, usage)
+}
+
+// Lookup the flag with the given long name, or nil if there is none.
+func (f *GNUFlagSet) Lookup(name string) *flag.Flag {
+	return f.formal[name]
+}
+
+// Shorthand returns the short name of the flag with the given long name, if any.
+func (f *GNUFlagSet) Shorthand(name string) string {
+	for short, long := range f.shorthands {
+		if long == name {
+			return short
+		}
+	}
+	return ""
+}
+
+// Set the value of the flag with the given long name.
+func (f *GNUFlagSet) Set(name, value string) error {
+	fl, ok := f.formal[name]
+	if !ok {
+		return fmt.Errorf("no such flag --%v", name)
+	}
+	if err := fl.Value.Set(value); err != nil {
+		return err
+	}
+	if f.actual == nil {
+		f.actual = map[string]*flag.Flag{}
+	}
+	f.actual[name] = fl
+	return nil
+}
+
+// Visit the flags which have been set, in lexicographical order of their long names.
+func (f *GNUFlagSet) Visit(fn func(*flag.Flag)) {
+	for _, name := range slices.Sorted(maps.Keys(f.actual)) {
+		fn(f.actual[name])
+	}
+}
+
+// VisitAll flags, in lexicographical order of their long names.
+func (f *GNUFlagSet) VisitAll(fn func(*flag.Flag)) {
+	for _, name := range slices.Sorted(maps.Keys(f.formal)) {
+		fn(f.formal[name])
+	}
+}
+
+// Parse the flags from the args, which must not include the program name.
+// Parsing stops at the first non-flag arg, or after "--".
+// It returns [flag.ErrHelp] if "-h" or "--help" is given but not defined.
+func (f *GNUFlagSet) Parse(args []string) error {
+	_, err := f.parse(args, false, nil)
+	return err
+}
+
+// Parsed returns whether [GNUFlagSet.Parse] has been called.
+func (f *GNUFlagSet) Parsed() bool {
+	return f.parsed
+}
+
+// Args returns the args remaining after parsing.
+func (f *GNUFlagSet) Args() []string {
+	return f.args
+}
+
+// SetOutput sets the output for usage and error messages. If nil, [os.Stderr] is used.
+func (f *GNUFlagSet) SetOutput(w io.Writer) {
+	f.output = w
+}
+
+// Output returns the output for usage and error messages.
+func (f *GNUFlagSet) Output() io.Writer {
+	if f.output == nil {
+		return os.Stderr
+	}
+	return f.output
+}
+
+// PrintDefaults prints the usage and default values of all flags to the output, like [flag.FlagSet.PrintDefaults].
+func (f *GNUFlagSet) PrintDefaults() {
+	f.VisitAll(func(fl *flag.Flag) {
+		var b strings.Builder
+		if short := f.Shorthand(fl.Name); short != "" {
+			b.WriteString("  -" + short + ", --" + fl.Name)
+		} else {
+			b.WriteString("      --" + fl.Name)
+		}
+		typ, usage := unquoteUsage(fl)
+		if typ != "" && !isBoolFlag(fl.Value) {
+			b.WriteString(" " + typ)
+		}
+		b.WriteString("\n    \t" + strings.ReplaceAll(usage, "\n", "\n    \t"))
+		switch fl.DefValue {
+		case "", "0", "false", "[]":
+		default:
+			_, _ = fmt.Fprintf(&b, " (default %q)", fl.DefValue)
+		}
+		_, _ = fmt.Fprintln(f.Output(), b.String())
+	})
+}
+
+// usage prints the usage with [GNUFlagSet.Usage], or the defaults if it's nil.
+func (f *GNUFlagSet) usage() {
+	if f.Usage != nil {
+		f.Usage()
+		return
+	}
+	_, _ = fmt.Fprintln(f.Output(), "Usage:")
+	f.PrintDefaults()
+}
+
+// parse the flags from the args and return the remaining args, interleaved or not, see [Interleaved].
+// Errors are printed with the usage. If invalid is not nil, invalid values are appended to it instead of stopping the parse.
+func (f *GNUFlagSet) parse(args []string, interleaved bool, invalid *[]error) ([]string, error) {
+	f.parsed = true
+	f.invalid = invalid
+	defer func() {
+		f.invalid = nil
+	}()
+
+	rest, err := f.parseArgs(args, interleaved)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintln(f.Output(), err)
+		}
+		f.usage()
+		return nil, err
+	}
+
+	f.args = rest
+	return rest, nil
+}
+
+func (f *GNUFlagSet) parseArgs(args []string, interleaved bool) ([]string, error) {
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			if interleaved {
+				return append(rest, args[i:]...), nil
+			}
+			return append(rest, args[i+1:]...), nil
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			if !interleaved {
+				return append(rest, args[i:]...), nil
+			}
+			rest = append(rest, arg)
+			continue
+		}
+
+		var consumed int
+		var known bool
+		var err error
+		if strings.HasPrefix(arg, "--") {
+			consumed, known, err = f.parseLong(arg, args[i+1:])
+		} else {
+			consumed, known, err = f.parseShort(arg, args[i+1:])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			// Unknown flags, including undefined help flags, are left for the routes and the router when interleaved
+			if !interleaved {
+				if arg == "-h" || arg == "--help" {
+					return nil, flag.ErrHelp
+				}
+				return nil, fmt.Errorf("flag provided but not defined: %v", arg)
+			}
+			rest = append(rest, arg)
+		}
+		i += consumed
+	}
+	return rest, nil
+}
+
+// parseLong flag arg like "--name", "--name=value", or "--no-name", with the value from next if needed.
+// It returns how many args from next are consumed, and whether the flag is known.
+func (f *GNUFlagSet) parseLong(arg string, next []string) (int, bool, error) {
+	name, value, hasValue := strings.Cut(arg[2:], "=")
+
+	fl, ok := f.formal[name]
+	if !ok {
+		if negated, ok := f.formal[strings.TrimPrefix(name, "no-")]; ok && strings.HasPrefix(name, "no-") && isBoolFlag(negated.Value) && !hasValue {
+			return 0, true, f.set(negated, "false", "--"+name)
+		}
+		return 0, false, nil
+	}
+
+	switch {
+	case hasValue:
+		return 0, true, f.set(fl, value, "--"+name)
+	case isBoolFlag(fl.Value):
+		return 0, true, f.set(fl, "true", "--"+name)
+	case len(next) > 0:
+		return 1, true, f.set(fl, next[0], "--"+name)
+	default:
+		return 0, true, fmt.Errorf("flag needs an argument: --%v", name)
+	}
+}
+
+// parseShort flag arg like "-v", "-ofile", "-o=file", or bundled like "-xvf", with the value from next if needed.
+// It returns how many args from next are consumed, and whether the flag is known.
+// A bundle with an unknown short flag is unknown as a whole, and none of its flags are set.
+func (f *GNUFlagSet) parseShort(arg string, next []string) (int, bool, error) {
+	shorts := arg[1:]
+	for _, c := range shorts {
+		name, ok := f.shorthands[string(c)]
+		if !ok {
+			return 0, false, nil
+		}
+		if !isBoolFlag(f.formal[name].Value) {
+			// The rest of the arg is the value
+			break
+		}
+	}
+
+	for i, c := range shorts {
+		short := string(c)
+
+		fl := f.formal[f.shorthands[short]]
+		if isBoolFlag(fl.Value) {
+			if err := f.set(fl, "true", "-"+short); err != nil {
+				return 0, true, err
+			}
+			continue
+		}
+
+		// The rest of the arg is the value, or else the next arg
+		value := strings.TrimPrefix(shorts[i+len(short):], "=")
+		if value != "" {
+			return 0, true, f.set(fl, value, "-"+short)
+		}
+		if len(next) > 0 {
+			return 1, true, f.set(fl, next[0], "-"+short)
+		}
+		return 0, true, fmt.Errorf("flag needs an argument: -%v", short)
+	}
+	return 0, true, nil
+}
+
+// set the flag value, with the flag as used in the args for errors.
+// Invalid values are appended to the invalid values while parsing, if any, see [GNUFlagSet.parse].
+func (f *GNUFlagSet) set(fl *flag.Flag, value, used string) error {
+	err := f.Set(fl.Name, value)
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("invalid value %q for flag %v: %w", value, used, err)
+	if f.invalid == nil {
+		return err
+	}
+
+	// The flag is given in the args, even with an invalid value, so it's not also reported as missing.
+	if f.actual == nil {
+		f.actual = map[string]*flag.Flag{}
+	}
+	f.actual[fl.Name] = fl
+	*f.invalid = append(*f.invalid, err)
+	return nil
+}
+
+// GNUFlags middleware is like [Flags], but with a [GNUFlagSet] for POSIX and GNU style flags.
+func GNUFlags(cb func(fs *GNUFlagSet), opts ...Option) clir.Middleware {
+	o := newOptions(opts)
+
+	describeFS := &GNUFlagSet{}
+	cb(describeFS)
+
+	return func(next clir.Runner) clir.Runner {
+		return describer{interleaved: o.interleaved, describe: func() clir.Inputs {
+			return clir.Inputs{Flags: o.flagInputs(describeFS, describeFS.Shorthand)}
+		}, RunnerFunc: func(ctx clir.Context) error {
+			fs := &GNUFlagSet{}
+			cb(fs)
+			fs.SetOutput(ctx.Err)
+
+			return runFlags(ctx, next, o, fs, "--", func(args []string, invalid *[]error) ([]string, error) {
+				return fs.parse(args, o.interleaved, invalid)
+			})
+		}}
+	}
+}
@@ -0,0 +1,291 @@
+package middleware_test
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+
+	"maragu.dev/is"
+
+	"maragu.dev/clir"
+	"maragu.dev/clir/middleware"
+)
+
+func TestGNUFlagSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"long flags", []string{"--verbose", "--output", "file", "--n=3"}, "true file 3 false [] "},
+		{"short flags", []string{"-v", "-o", "file", "-x"}, "true file 0 true [] "},
+		{"short flag with attached value", []string{"-ofile"}, "false file 0 false [] "},
+		{"short flag with equal sign", []string{"-o=file"}, "false file 0 false [] "},
+		{"bundled short flags", []string{"-xvo", "file"}, "true file 0 true [] "},
+		{"bundled short flags with attached value", []string{"-xvofile"}, "true file 0 true [] "},
+		{"negated boolean flag", []string{"--verbose", "--no-verbose"}, "false  0 false [] "},
+		{"boolean flag with value", []string{"--verbose=true", "--x=false"}, "true  0 false [] "},
+		{"stops at first non-flag", []string{"-v", "a", "-x"}, "true  0 false [a -x] a"},
+		{"stops after double dash", []string{"-v", "--", "-x"}, "true  0 false [-x] -x"},
+		{"single dash is an arg", []string{"-", "-v"}, "false  0 false [- -v] -"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fs middleware.GNUFlagSet
+			v := fs.BoolP("verbose", "v", false, "")
+			x := fs.BoolP("x", "x", false, "")
+			o := fs.StringP("output", "o", "", "")
+			n := fs.Int("n", 0, "")
+
+			err := fs.Parse(test.args)
+			is.NotError(t, err)
+			is.True(t, fs.Parsed())
+			var first string
+			if len(fs.Args()) > 0 {
+				first = fs.Args()[0]
+			}
+			is.Equal(t, test.expected, fmt.Sprint(*v, " ", *o, " ", *n, " ", *x, " ", fs.Args(), " ", first))
+		})
+	}
+
+	errorTests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"unknown long flag", []string{"--doesnotexist"}, "flag provided but not defined: --doesnotexist"},
+		{"unknown short flag", []string{"-q"}, "flag provided but not defined: -q"},
+		{"unknown short flag in bundle", []string{"-vq"}, "flag provided but not defined: -vq"},
+		{"unknown first short flag in bundle", []string{"-qv"}, "flag provided but not defined: -qv"},
+		{"missing long flag value", []string{"--output"}, "flag needs an argument: --output"},
+		{"missing short flag value", []string{"-vo"}, "flag needs an argument: -o"},
+		{"invalid value", []string{"--n", "notanumber"}, `invalid value "notanumber" for flag --n: strconv.ParseInt: parsing "notanumber": invalid syntax`},
+		{"negated non-boolean flag", []string{"--no-output"}, "flag provided but not defined: --no-output"},
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.name, func(t *testing.T) {
+			var b strings.Builder
+			var fs middleware.GNUFlagSet
+			fs.SetOutput(&b)
+			fs.BoolP("verbose", "v", false, "")
+			fs.StringP("output", "o", "", "")
+			fs.Int("n", 0, "")
+
+			err := fs.Parse(test.args)
+			is.True(t, err != nil)
+			is.Equal(t, test.expected, err.Error())
+			is.True(t, strings.HasPrefix(b.String(), test.expected+"\nUsage:\n"))
+		})
+	}
+
+	t.Run("returns flag.ErrHelp on help flags if not defined", func(t *testing.T) {
+		for _, arg := range []string{"-h", "--help"} {
+			var b strings.Builder
+			var fs middleware.GNUFlagSet
+			fs.SetOutput(&b)
+			err := fs.Parse([]string{arg})
+			is.True(t, errors.Is(err, flag.ErrHelp))
+			is.Equal(t, "Usage:\n", b.String())
+		}
+	})
+
+	t.Run("works with any flag.Value", func(t *testing.T) {
+		var fs middleware.GNUFlagSet
+		var level string
+		fs.VarP(flagFunc(func(s string) error {
+			level = s
+			return nil
+		}), "level", "l", "")
+
+		err := fs.Parse([]string{"-lhigh"})
+		is.NotError(t, err)
+		is.Equal(t, "high", level)
+	})
+
+	t.Run("panics on redefined flags", func(t *testing.T) {
+		defer func() {
+			is.Equal(t, "flag shorthand redefined: v", recover())
+		}()
+
+		var fs middleware.GNUFlagSet
+		fs.BoolP("verbose", "v", false, "")
+		fs.BoolP("version", "v", false, "")
+	})
+
+	t.Run("prints defaults", func(t *testing.T) {
+		var b strings.Builder
+		var fs middleware.GNUFlagSet
+		fs.SetOutput(&b)
+		fs.BoolP("verbose", "v", false, "verbose output")
+		fs.StringP("output", "o", "out.txt", "output `file`")
+		fs.Int("n", 0, "number of things")
+
+		fs.PrintDefaults()
+		is.Equal(t, `      --n int
+    	number of things
+  -o, --output file
+    	output file (default "out.txt")
+  -v, --verbose
+    	verbose output
+`, b.String())
+	})
+}
+
+// flagFunc is a [flag.Value] calling the function on Set.
+type flagFunc func(string) error
+
+func (f flagFunc) Set(s string) error { return f(s) }
+
+func (f flagFunc) String() string { return "" }
+
+func TestGNUFlags(t *testing.T) {
+	t.Run("can set flags on a route, interleaved and bound to environment variables", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		var v *bool
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			v = fs.BoolP("verbose", "v", false, "")
+			fs.StringP("name", "n", "World", "")
+			fs.Bool("dry-run", false, "")
+		}, middleware.Interleaved(), middleware.WithEnvPrefix("MYTOOL")))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			name, _ := middleware.Flag[string](ctx, "name")
+			dryRun, _ := middleware.Flag[bool](ctx, "dry-run")
+			source, _ := middleware.FlagSource(ctx, "dry-run")
+			ctx.Println(name, dryRun, source, ctx.Args)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"a", "-vn", "Gopher", "b"},
+			Env:  env(map[string]string{"MYTOOL_DRY_RUN": "true"}),
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, *v)
+		is.Equal(t, "Gopher true env [a b]\n", b.String())
+	})
+
+	t.Run("returns a validation error on invalid flag values", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			fs.Int("n", 0, "")
+		}, middleware.WithEnv("n", "N")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"--n", "notanumber"},
+			Err:  &b,
+		})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.True(t, strings.HasSuffix(err.Error(), `invalid value "notanumber" for flag --n: strconv.ParseInt: parsing "notanumber": invalid syntax`))
+
+		err = r.Run(clir.Context{
+			Env: env(map[string]string{"N": "notanumber"}),
+			Err: &b,
+		})
+		is.True(t, strings.HasSuffix(err.Error(), `invalid value "notanumber" for flag --n from environment variable N: strconv.ParseInt: parsing "notanumber": invalid syntax`))
+	})
+
+	t.Run("leaves bundles with an unknown short flag in the args when interleaved, in any order", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			fs.BoolP("verbose", "v", false, "")
+		}, middleware.Interleaved()))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			v, _ := middleware.Flag[bool](ctx, "verbose")
+			ctx.Println(v, ctx.Args)
+			return nil
+		})
+
+		for _, arg := range []string{"-xv", "-vx"} {
+			var b strings.Builder
+			err := r.Run(clir.Context{
+				Args: []string{"a", arg},
+				Out:  &b,
+			})
+			is.NotError(t, err)
+			is.Equal(t, "false [a "+arg+"]\n", b.String())
+		}
+	})
+
+	t.Run("can use -h as a shorthand instead of help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			fs.StringP("host", "h", "localhost", "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			host, _ := middleware.Flag[string](ctx, "host")
+			ctx.Println(host)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-h", "example.com"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "example.com\n", b.String())
+
+		b.Reset()
+		err = r.Run(clir.Context{
+			Args: []string{"--help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "  -h, --host string  (default \"localhost\")\n"))
+	})
+
+	t.Run("describes flags in router help and completion", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			fs.BoolP("verbose", "v", false, "verbose output")
+			fs.StringP("output", "o", "", "output `file`")
+			fs.Int("n", 0, "number of things")
+		}))
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.RouteFunc("salsa", func(ctx clir.Context) error {
+				return nil
+			})
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"--help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "\nFlags:\n      --n int        number of things\n  -o, --output file  output file\n  -v, --verbose      verbose output\n"))
+
+		var values []string
+		for _, c := range r.Complete(clir.Context{}, []string{"-"}) {
+			values = append(values, c.Value)
+		}
+		is.Equal(t, "--n,-o,--output,-v,--verbose", strings.Join(values, ","))
+
+		values = nil
+		for _, c := range r.Complete(clir.Context{}, []string{"-vo", "file", "--no-verbose", "dance", ""}) {
+			values = append(values, c.Value)
+		}
+		is.Equal(t, "salsa", strings.Join(values, ","))
+	})
+}
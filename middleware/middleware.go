@@ -4,39 +4,502 @@ package middleware
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 
 	"maragu.dev/clir"
 )
 
+// Option for [Flags], [GNUFlags], [Args], and [Config].
+type Option func(o *options)
+
+type options struct {
+	completers  map[string]clir.Completer
+	configEnv   string
+	configFlag  string
+	configXDG   string
+	decoders    map[string]Decoder
+	env         map[string]string
+	envPrefix   string
+	interleaved bool
+	noExtraArgs bool
+	required    map[string]bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		completers: map[string]clir.Completer{},
+		decoders:   map[string]Decoder{".json": JSONDecoder},
+		env:        map[string]string{},
+		required:   map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCompleter sets the [clir.Completer] for the flag or positional argument with the given name.
+func WithCompleter(name string, c clir.Completer) Option {
+	return func(o *options) {
+		o.completers[name] = c
+	}
+}
+
+// WithEnv binds the flag with the given name to the environment variable envVar, for [Flags] and [GNUFlags].
+// It takes precedence over [WithEnvPrefix].
+func WithEnv(name, envVar string) Option {
+	return func(o *options) {
+		o.env[name] = envVar
+	}
+}
+
+// WithEnvPrefix binds all flags to environment variables named by the prefix and the flag name, for [Flags] and [GNUFlags].
+// The flag name is upper-cased, with dashes and dots replaced by underscores,
+// so the flag "dry-run" with the prefix "MYTOOL" is bound to the environment variable "MYTOOL_DRY_RUN".
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.envPrefix = prefix
+	}
+}
+
+// envVar returns the name of the environment variable bound to the flag with the given name, if any.
+func (o *options) envVar(name string) string {
+	if envVar, ok := o.env[name]; ok {
+		return envVar
+	}
+	if o.envPrefix == "" {
+		return ""
+	}
+	return o.envPrefix + "_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// Required marks the flags or positional arguments with the given names as required, for [Flags], [GNUFlags], and [Args].
+// Flags must be set by the args, an environment variable, or a config file.
+// All missing and invalid inputs are reported together in a [clir.ValidationError].
+func Required(names ...string) Option {
+	return func(o *options) {
+		for _, name := range names {
+			o.required[name] = true
+		}
+	}
+}
+
+// NoExtraArgs reports args remaining after the positional arguments as a problem in a [clir.ValidationError], for [Args].
+// Use it for routes which do not take subcommands or other args.
+func NoExtraArgs() Option {
+	return func(o *options) {
+		o.noExtraArgs = true
+	}
+}
+
+// Interleaved parses flags anywhere in the args, GNU style, for [Flags] and [GNUFlags].
+// Args which are not known flags, like positional args, subcommands, and flags for routes further down,
+// are left in the [clir.Context.Args] in the same order, so flags set on a router are recognized after the names of its branches.
+// Undefined help flags are left as well, for the router to render help, or for the routes.
+// A "--" stops parsing, and is left in the args with the args after it. [Args] skips it, and parses the args after it.
+func Interleaved() Option {
+	return func(o *options) {
+		o.interleaved = true
+	}
+}
+
+// Source of a flag value, see [FlagSource].
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceConfig  Source = "config"
+	SourceEnv     Source = "env"
+	SourceArgs    Source = "args"
+)
+
+// parsedFlags by name, added to the [clir.Context] by [Flags].
+type parsedFlags map[string]parsedFlag
+
+// parsedFlag value and its [Source].
+type parsedFlag struct {
+	value  flag.Value
+	source Source
+}
+
+// FlagSource returns the [Source] of the value of the flag with the given name, as set by [Flags].
+func FlagSource(ctx clir.Context, name string) (Source, bool) {
+	flags, _ := clir.Value[parsedFlags](ctx)
+	f, ok := flags[name]
+	return f.source, ok
+}
+
+// Flag returns the value of the flag with the given name, as parsed by [Flags] in this run, and whether it was found.
+// The value is from [flag.Getter.Get] if the [flag.Value] satisfies it, like the flag values of the standard library,
+// so a flag from [flag.FlagSet.Int] is an int. Otherwise, T must be the type of the [flag.Value] itself.
+func Flag[T any](ctx clir.Context, name string) (T, bool) {
+	flags, _ := clir.Value[parsedFlags](ctx)
+	f, ok := flags[name]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return valueOf[T](f.value)
+}
+
+// valueOf returns the value of the [flag.Value] from [flag.Getter.Get] if it's of type T, or the [flag.Value] itself.
+func valueOf[T any](value flag.Value) (T, bool) {
+	if g, ok := value.(flag.Getter); ok {
+		if v, ok := g.Get().(T); ok {
+			return v, true
+		}
+	}
+	v, ok := value.(T)
+	return v, ok
+}
+
 // Flags middleware allows you to set flags on a route.
-func Flags(cb func(fs *flag.FlagSet)) clir.Middleware {
-	fs := flag.NewFlagSet("", flag.ContinueOnError)
-	cb(fs)
+// Unknown flags and flags without a value are [clir.UsageError]-s. Invalid values and missing required flags,
+// see [Required], are reported together in a [clir.ValidationError].
+// Flags can be bound to environment variables with [WithEnv] and [WithEnvPrefix], which are looked up with [clir.Context.LookupEnv],
+// and get values from a config file loaded by [Config].
+// Values from the args take precedence over values from environment variables, then config files, then defaults.
+// Get the source of each value with [FlagSource].
+// The flags are described for help output and completion with [clir.Describer].
+//
+// The callback is called with a new [flag.FlagSet] on every run, so values do not leak between runs,
+// and once when the middleware is created, to describe the flags.
+// To run a router concurrently, get the values with [Flag] instead of the pointers returned by the [flag.FlagSet].
+// A pointer passed on when the router is set up, like to a middleware constructor, is from the flag set describing the flags,
+// so it always holds the default value.
+func Flags(cb func(fs *flag.FlagSet), opts ...Option) clir.Middleware {
+	o := newOptions(opts)
+
+	describeFS := flag.NewFlagSet("", flag.ContinueOnError)
+	cb(describeFS)
 
 	return func(next clir.Runner) clir.Runner {
-		return clir.RunnerFunc(func(ctx clir.Context) error {
+		return describer{interleaved: o.interleaved, describe: func() clir.Inputs {
+			return clir.Inputs{Flags: o.flagInputs(describeFS, nil)}
+		}, RunnerFunc: func(ctx clir.Context) error {
+			fs := flag.NewFlagSet("", flag.ContinueOnError)
+			cb(fs)
 			fs.SetOutput(ctx.Err)
-			if err := fs.Parse(ctx.Args); err != nil {
-				if errors.Is(err, flag.ErrHelp) {
-					return nil
+
+			return runFlags(ctx, next, o, fs, "-", func(args []string, invalid *[]error) ([]string, error) {
+				return parse(fs, args, o.interleaved, invalid)
+			})
+		}}
+	}
+}
+
+// flagSet is the part of [flag.FlagSet] and [GNUFlagSet] used by [Flags] and [GNUFlags].
+type flagSet interface {
+	Set(name, value string) error
+	Visit(fn func(*flag.Flag))
+	VisitAll(fn func(*flag.Flag))
+}
+
+// flagInputs describes the flags in the flag set, with the short names from shorthand for GNU style flags if it's not nil.
+func (o *options) flagInputs(fs flagSet, shorthand func(name string) string) []clir.Input {
+	var inputs []clir.Input
+	fs.VisitAll(func(f *flag.Flag) {
+		i := flagInput(f)
+		i.Completer = o.completers[f.Name]
+		i.Env = o.envVar(f.Name)
+		i.Required = o.required[f.Name]
+		if shorthand != nil {
+			i.Long = true
+			i.Short = shorthand(f.Name)
+		}
+		inputs = append(inputs, i)
+	})
+	return inputs
+}
+
+// runFlags parses the flags with [parseFlags], and runs the next runner with the remaining args and the [parsedFlags].
+func runFlags(ctx clir.Context, next clir.Runner, o *options, fs flagSet, dashes string, parse func(args []string, invalid *[]error) ([]string, error)) error {
+	ctx, problems, err := parseFlags(ctx, o, fs, dashes, parse)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if len(problems) > 0 {
+		return &clir.ValidationError{Problems: problems}
+	}
+	return next.Run(ctx)
+}
+
+// parseFlags parses the args into the flag set, sets values not from the args from environment variables or config files,
+// and checks required flags. It returns the context with the remaining args and the [parsedFlags], and the problems found.
+// Flag names in problems are prefixed with dashes.
+// The parse function appends invalid values from the args to invalid instead of stopping, so they are reported with the other problems.
+// Other errors from parse are returned as [clir.UsageError]-s, except [flag.ErrHelp].
+func parseFlags(ctx clir.Context, o *options, fs flagSet, dashes string, parse func(args []string, invalid *[]error) ([]string, error)) (clir.Context, []error, error) {
+	fs.VisitAll(func(f *flag.Flag) {
+		setAbs(ctx, f.Value)
+	})
+
+	var problems []error
+	args, err := parse(ctx.Args, &problems)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ctx, nil, err
+		}
+		return ctx, nil, &clir.UsageError{Err: err}
+	}
+
+	flags, _ := clir.Value[parsedFlags](ctx)
+	flags = maps.Clone(flags)
+	if flags == nil {
+		flags = parsedFlags{}
+	}
+
+	fromArgs := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		fromArgs[f.Name] = true
+	})
+
+	// Set values not from the args from environment variables, or else config files.
+	c, _ := clir.Value[config](ctx)
+	fs.VisitAll(func(f *flag.Flag) {
+		if fromArgs[f.Name] {
+			flags[f.Name] = parsedFlag{value: f.Value, source: SourceArgs}
+			return
+		}
+
+		if envVar := o.envVar(f.Name); envVar != "" {
+			if v, ok := ctx.LookupEnv(envVar); ok {
+				if err := fs.Set(f.Name, v); err != nil {
+					problems = append(problems, fmt.Errorf("invalid value %q for flag %v%v from environment variable %v: %w", v, dashes, f.Name, envVar, err))
 				}
-				return err
+				flags[f.Name] = parsedFlag{value: f.Value, source: SourceEnv}
+				return
 			}
-			ctx.Args = fs.Args()
-			return next.Run(ctx)
+		}
+
+		if v, ok := c.values[f.Name]; ok {
+			if err := fs.Set(f.Name, v); err != nil {
+				problems = append(problems, fmt.Errorf("invalid value %q for flag %v%v from config file %v: %w", v, dashes, f.Name, c.path, err))
+			}
+			flags[f.Name] = parsedFlag{value: f.Value, source: SourceConfig}
+			return
+		}
+
+		flags[f.Name] = parsedFlag{value: f.Value, source: SourceDefault}
+		if o.required[f.Name] {
+			problems = append(problems, fmt.Errorf("missing required flag %v%v", dashes, f.Name))
+		}
+	})
+
+	// Record whether a "--" is left by interleaved parsing, so positional arguments skip it, see [terminated].
+	// Parsing which is not interleaved consumes the "--" if it gets to it.
+	switch {
+	case o.interleaved && slices.Contains(args, "--"):
+		ctx = clir.WithValue(ctx, terminated(true))
+	case countTerminators(args) < countTerminators(ctx.Args):
+		ctx = clir.WithValue(ctx, terminated(false))
+	}
+
+	ctx.Args = args
+	return clir.WithValue(ctx, flags), problems, nil
+}
+
+// terminated is true in the [clir.Context] if the first "--" in the args was left by [Interleaved] flag parsing.
+type terminated bool
+
+// countTerminators in the args, which are "--".
+func countTerminators(args []string) int {
+	var n int
+	for _, arg := range args {
+		if arg == "--" {
+			n++
+		}
+	}
+	return n
+}
+
+// parse the args with the [flag.FlagSet] and return the remaining args, interleaved or not, see [Interleaved].
+// Invalid values are appended to invalid instead of stopping the parse.
+func parse(fs *flag.FlagSet, args []string, interleaved bool, invalid *[]error) ([]string, error) {
+	restore := collectInvalid(fs, invalid)
+	defer restore()
+
+	if !interleaved {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		return fs.Args(), nil
+	}
+
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			rest = append(rest, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(arg[1:], "-"), "=")
+		// Unknown flags, including undefined help flags, are left for the routes and the router, which handles help.
+		f := fs.Lookup(name)
+		if f == nil {
+			rest = append(rest, arg)
+			continue
+		}
+
+		// Parse known flags one at a time, with their value if given separately.
+		flagArgs := []string{arg}
+		if f != nil && !hasValue && !isBoolFlag(f.Value) && i+1 < len(args) {
+			i++
+			flagArgs = append(flagArgs, args[i])
+		}
+		if err := fs.Parse(flagArgs); err != nil {
+			return nil, err
+		}
+	}
+	return rest, nil
+}
+
+// collectInvalid wraps the values of the flag set in [invalidValue]-s appending to invalid,
+// and returns a function restoring the values. The values are also restored before usage is printed on parse errors,
+// so it describes the actual values.
+func collectInvalid(fs *flag.FlagSet, invalid *[]error) func() {
+	values := map[string]flag.Value{}
+	fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value
+		f.Value = invalidValue{Value: f.Value, name: f.Name, invalid: invalid}
+	})
+
+	restoreValues := func() {
+		fs.VisitAll(func(f *flag.Flag) {
+			f.Value = values[f.Name]
 		})
 	}
+
+	usage := fs.Usage
+	fs.Usage = func() {
+		restoreValues()
+		if usage != nil {
+			usage()
+			return
+		}
+		_, _ = fmt.Fprintln(fs.Output(), "Usage:")
+		fs.PrintDefaults()
+	}
+
+	return func() {
+		restoreValues()
+		fs.Usage = usage
+	}
+}
+
+// invalidValue is a [flag.Value] appending errors from setting the value to invalid, instead of returning them.
+type invalidValue struct {
+	flag.Value
+	name    string
+	invalid *[]error
+}
+
+func (v invalidValue) Set(s string) error {
+	if err := v.Value.Set(s); err != nil {
+		*v.invalid = append(*v.invalid, fmt.Errorf("invalid value %q for flag -%v: %w", s, v.name, err))
+	}
+	return nil
+}
+
+func (v invalidValue) IsBoolFlag() bool { return isBoolFlag(v.Value) }
+
+// absSetter is a value with relative paths, like from [ExistingPath], which resolves them with the abs function.
+type absSetter interface {
+	setAbs(abs func(path string) string)
+}
+
+// setAbs makes the value resolve relative paths with [clir.Context.Abs], if it's an [absSetter].
+func setAbs(ctx clir.Context, v flag.Value) {
+	if a, ok := v.(absSetter); ok {
+		a.setAbs(ctx.Abs)
+	}
+}
+
+// isBoolFlag returns whether the [flag.Value] is for a boolean flag, which takes no value.
+func isBoolFlag(v flag.Value) bool {
+	b, ok := v.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// describer is a [clir.Runner] which also satisfies [clir.Describer].
+// It also tells whether its flags are parsed [Interleaved], for [Config].
+type describer struct {
+	clir.RunnerFunc
+	describe    func() clir.Inputs
+	interleaved bool
+}
+
+// Describe satisfies [clir.Describer].
+func (d describer) Describe() clir.Inputs {
+	return d.describe()
+}
+
+// unquoteUsage is like [flag.UnquoteUsage], but also with the type names of the values in this package.
+func unquoteUsage(f *flag.Flag) (string, string) {
+	typ, usage := flag.UnquoteUsage(f)
+	if typ != "value" {
+		return typ, usage
+	}
+	switch v := f.Value.(type) {
+	case interface{ typeName() string }:
+		typ = v.typeName()
+	case *stringValue:
+		typ = "string"
+	case *intValue:
+		typ = "int"
+	case *float64Value:
+		typ = "float"
+	}
+	return typ, usage
+}
+
+// flagInput describes a [flag.Flag] as a [clir.Input].
+func flagInput(f *flag.Flag) clir.Input {
+	typ, usage := unquoteUsage(f)
+	return clir.Input{
+		Name:    f.Name,
+		Type:    typ,
+		Usage:   usage,
+		Default: f.DefValue,
+		Bool:    isBoolFlag(f.Value),
+		Choices: choicesOf(f.Value),
+	}
+}
+
+// choicesOf the value, if it has a Choices method like the values from [Choice] and [ChoiceFold].
+func choicesOf(v flag.Value) []string {
+	if c, ok := v.(interface{ Choices() []string }); ok {
+		return c.Choices()
+	}
+	return nil
 }
 
 // ArgSet is like [flag.FlagSet] but for positional arguments.
 // The order of calls is significant.
+//
+// One of the arguments can be variadic, taking any number of args, like the sources in "cp <src>... <dst>".
+// Arguments before it get the first args, and arguments after it the last args.
 type ArgSet struct {
-	Usage  func()
-	args   []string
-	formal []*flag.Flag
-	w      io.Writer
+	Usage       func()
+	args        []string
+	formal      []*flag.Flag
+	noExtraArgs bool
+	required    map[string]bool
+	variadic    string
+	w           io.Writer
 }
 
 // String defines a string positional argument.
@@ -87,61 +550,258 @@ func (a *ArgSet) Float64Var(p *float64, name string, value float64, usage string
 	a.Var(newFloat64Value(value, p), name, usage)
 }
 
+// Strings defines a variadic string positional argument, see [ArgSet.VariadicVar].
+func (a *ArgSet) Strings(name string, usage string) *[]string {
+	p := new([]string)
+	a.StringsVar(p, name, usage)
+	return p
+}
+
+// StringsVar defines a variadic string positional argument with a pointer.
+func (a *ArgSet) StringsVar(p *[]string, name string, usage string) {
+	a.VariadicVar(newSliceValue(p, newStringValue), name, usage)
+}
+
+// Ints defines a variadic int positional argument, see [ArgSet.VariadicVar].
+func (a *ArgSet) Ints(name string, usage string) *[]int {
+	p := new([]int)
+	a.IntsVar(p, name, usage)
+	return p
+}
+
+// IntsVar defines a variadic int positional argument with a pointer.
+func (a *ArgSet) IntsVar(p *[]int, name string, usage string) {
+	a.VariadicVar(newSliceValue(p, newIntValue), name, usage)
+}
+
+// Var defines a positional argument with a [flag.Value].
 func (a *ArgSet) Var(value flag.Value, name string, usage string) {
 	f := &flag.Flag{Name: name, Usage: usage, Value: value, DefValue: value.String()}
 	a.formal = append(a.formal, f)
 }
 
+// VariadicVar defines a variadic positional argument, with [flag.Value.Set] called for each of its args.
+// It panics if there already is a variadic positional argument.
+func (a *ArgSet) VariadicVar(value flag.Value, name string, usage string) {
+	if a.variadic != "" {
+		panic("cannot have more than one variadic positional argument")
+	}
+	a.variadic = name
+	a.Var(value, name, usage)
+}
+
+// Parse the args into the positional arguments.
+// Invalid values, missing required arguments, see [Required], and extra args, see [NoExtraArgs],
+// are reported together in a [clir.ValidationError].
 func (a *ArgSet) Parse(args []string) error {
 	a.args = args
 
 	// Reset all positional arguments to their declared defaults before parsing.
 	for _, f := range a.formal {
+		if r, ok := f.Value.(interface{ reset() }); ok {
+			r.reset()
+			continue
+		}
+		if f.Name == a.variadic {
+			continue
+		}
 		if err := f.Value.Set(f.DefValue); err != nil {
 			return err
 		}
 	}
 
-	// Process all positional arguments based on formal definitions.
-	for i, f := range a.formal {
+	// Assign args to the positional arguments before the variadic one from the start,
+	// and to the ones after it from the end, leaving the args in between for the variadic one.
+	before, after := a.formal, []*flag.Flag(nil)
+	var variadic *flag.Flag
+	if i := slices.IndexFunc(a.formal, func(f *flag.Flag) bool { return f.Name == a.variadic }); i >= 0 {
+		before, variadic, after = a.formal[:i], a.formal[i], a.formal[i+1:]
+	}
+
+	var problems []error
+	set := func(f *flag.Flag, arg string) {
+		if err := f.Value.Set(arg); err != nil {
+			problems = append(problems, fmt.Errorf("invalid value %q for argument %v: %w", arg, f.Name, err))
+		}
+	}
+	missing := func(f *flag.Flag) {
+		if a.required[f.Name] {
+			problems = append(problems, fmt.Errorf("missing required argument %v", f.Name))
+		}
+	}
+
+	// Process all positional arguments based on formal definitions, collecting all problems.
+	for i, f := range before {
 		if i < len(args) {
-			// Set the value from the args.
-			if err := f.Value.Set(args[i]); err != nil {
-				return err
+			set(f, args[i])
+			continue
+		}
+		missing(f)
+	}
+	rest := args[min(len(before), len(args)):]
+
+	if variadic == nil {
+		if a.noExtraArgs && len(rest) > 0 {
+			problems = append(problems, fmt.Errorf("too many arguments: %v", strings.Join(rest, " ")))
+		}
+		a.args = rest
+	} else {
+		n := max(len(rest)-len(after), 0)
+		for _, arg := range rest[:n] {
+			set(variadic, arg)
+		}
+		if n == 0 {
+			missing(variadic)
+		}
+		for i, f := range after {
+			if n+i < len(rest) {
+				set(f, rest[n+i])
+				continue
 			}
+			missing(f)
 		}
+		a.args = nil
+	}
+
+	if len(problems) > 0 {
+		return &clir.ValidationError{Problems: problems}
 	}
 
 	return nil
 }
 
+// Args returns the args remaining after the positional arguments.
 func (a *ArgSet) Args() []string {
-	// Return any remaining args after the positional ones we consumed
-	if len(a.formal) < len(a.args) {
-		return a.args[len(a.formal):]
+	if a.args == nil {
+		return []string{}
+	}
+	return a.args
+}
+
+// Synopsis of the positional arguments, like "<src>... <dst>", see [clir.Input.Synopsis].
+func (a *ArgSet) Synopsis() string {
+	var synopses []string
+	for _, f := range a.formal {
+		synopses = append(synopses, a.input(f).Synopsis())
+	}
+	return strings.Join(synopses, " ")
+}
+
+// input describes the positional argument as a [clir.Input].
+func (a *ArgSet) input(f *flag.Flag) clir.Input {
+	return clir.Input{
+		Name:     f.Name,
+		Usage:    f.Usage,
+		Default:  f.DefValue,
+		Choices:  choicesOf(f.Value),
+		Required: a.required[f.Name],
+		Variadic: f.Name == a.variadic,
 	}
-	return []string{}
 }
 
 func (a *ArgSet) SetOutput(w io.Writer) {
 	a.w = w
 }
 
+// parsedArgs by name, added to the [clir.Context] by [Args].
+type parsedArgs map[string]flag.Value
+
+// Arg returns the value of the positional argument with the given name, as parsed by [Args] in this run,
+// and whether it was found. The value is from [flag.Getter.Get] if the [flag.Value] satisfies it,
+// like the values of [ArgSet.String] and friends. Otherwise, T must be the type of the [flag.Value] itself.
+func Arg[T any](ctx clir.Context, name string) (T, bool) {
+	args, _ := clir.Value[parsedArgs](ctx)
+	value, ok := args[name]
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return valueOf[T](value)
+}
+
 // Args middleware allows you to set positional arguments on a route.
-func Args(cb func(as *ArgSet)) clir.Middleware {
-	as := &ArgSet{}
-	cb(as)
+// Invalid values, missing required arguments, and extra args are reported together in a [clir.ValidationError].
+// The arguments are described for help output and completion with [clir.Describer].
+//
+// Like with [Flags], the callback is called with a new [ArgSet] on every run, and once when the middleware is created.
+// To run a router concurrently, get the values with [Arg] instead of the pointers returned by the [ArgSet].
+// Like with [Flags], a pointer passed on when the router is set up always holds the default value.
+func Args(cb func(as *ArgSet), opts ...Option) clir.Middleware {
+	o := newOptions(opts)
+
+	describeAS := &ArgSet{required: o.required}
+	cb(describeAS)
 
 	return func(next clir.Runner) clir.Runner {
-		return clir.RunnerFunc(func(ctx clir.Context) error {
-			as.SetOutput(ctx.Err)
-			if err := as.Parse(ctx.Args); err != nil {
-				return err
-			}
-			ctx.Args = as.Args()
-			return next.Run(ctx)
-		})
+		return describer{describe: func() clir.Inputs {
+			return clir.Inputs{Args: o.argInputs(describeAS)}
+		}, RunnerFunc: func(ctx clir.Context) error {
+			as := &ArgSet{noExtraArgs: o.noExtraArgs, required: o.required}
+			cb(as)
+			return runArgs(ctx, next, as)
+		}}
+	}
+}
+
+// argInputs describes the positional arguments in the arg set.
+func (o *options) argInputs(as *ArgSet) []clir.Input {
+	var inputs []clir.Input
+	for _, f := range as.formal {
+		i := as.input(f)
+		i.Completer = o.completers[f.Name]
+		inputs = append(inputs, i)
+	}
+	return inputs
+}
+
+// runArgs parses the args into the arg set, and runs the next runner with the remaining args and the [parsedArgs].
+func runArgs(ctx clir.Context, next clir.Runner, as *ArgSet) error {
+	ctx, problems, err := parseArgs(ctx, as)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &clir.ValidationError{Problems: problems}
 	}
+	return next.Run(ctx)
+}
+
+// parseArgs parses the args into the arg set, and returns the context with the remaining args and the [parsedArgs],
+// and the problems from the [clir.ValidationError] returned by [ArgSet.Parse], if any.
+func parseArgs(ctx clir.Context, as *ArgSet) (clir.Context, []error, error) {
+	as.SetOutput(ctx.Err)
+	for _, f := range as.formal {
+		setAbs(ctx, f.Value)
+	}
+
+	// Skip the "--" left by interleaved flag parsing, which terminates the flags, not the positional arguments.
+	positional := ctx.Args
+	if t, _ := clir.Value[terminated](ctx); t {
+		if i := slices.Index(positional, "--"); i >= 0 {
+			positional = slices.Delete(slices.Clone(positional), i, i+1)
+		}
+	}
+
+	var problems []error
+	if err := as.Parse(positional); err != nil {
+		var validationErr *clir.ValidationError
+		if !errors.As(err, &validationErr) {
+			return ctx, nil, err
+		}
+		problems = validationErr.Problems
+	}
+
+	args, _ := clir.Value[parsedArgs](ctx)
+	args = maps.Clone(args)
+	if args == nil {
+		args = parsedArgs{}
+	}
+	for _, f := range as.formal {
+		args[f.Name] = f.Value
+	}
+
+	ctx.Args = as.Args()
+	return clir.WithValue(ctx, args), problems, nil
 }
 
 // Value implementations for different types
@@ -202,6 +862,9 @@ func (b *boolValue) Get() any { return bool(*b) }
 
 func (b *boolValue) String() string { return strconv.FormatBool(bool(*b)) }
 
+// IsBoolFlag satisfies the interface checked by [flag.FlagSet] and [GNUFlagSet] for boolean flags, which take no value.
+func (b *boolValue) IsBoolFlag() bool { return true }
+
 type float64Value float64
 
 func newFloat64Value(val float64, p *float64) *float64Value {
@@ -221,3 +884,29 @@ func (f *float64Value) Set(val string) error {
 func (f *float64Value) Get() any { return float64(*f) }
 
 func (f *float64Value) String() string { return strconv.FormatFloat(float64(*f), 'g', -1, 64) }
+
+// sliceValue is a [flag.Value] for variadic positional arguments, which appends on every call to Set.
+type sliceValue[T any] struct {
+	p        *[]T
+	newValue func(val T, p *T) flag.Value
+}
+
+func newSliceValue[T any, V flag.Value](p *[]T, newValue func(val T, p *T) V) *sliceValue[T] {
+	*p = nil
+	return &sliceValue[T]{p: p, newValue: func(val T, p *T) flag.Value { return newValue(val, p) }}
+}
+
+func (s *sliceValue[T]) Set(val string) error {
+	var v T
+	if err := s.newValue(v, &v).Set(val); err != nil {
+		return err
+	}
+	*s.p = append(*s.p, v)
+	return nil
+}
+
+func (s *sliceValue[T]) Get() any { return *s.p }
+
+func (s *sliceValue[T]) String() string { return fmt.Sprint(*s.p) }
+
+func (s *sliceValue[T]) reset() { *s.p = nil }
@@ -1,9 +1,13 @@
 package middleware_test
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 
 	"maragu.dev/is"
@@ -75,10 +79,8 @@ func TestFlags(t *testing.T) {
 				r := clir.NewRouter()
 
 				var v *bool
-				var outerFS *flag.FlagSet
 				r.Use(middleware.Flags(func(fs *flag.FlagSet) {
-					outerFS = fs
-					v = fs.Bool("v", false, "")
+					v = fs.Bool("v", false, "verbose output")
 				}))
 
 				var called bool
@@ -87,23 +89,795 @@ func TestFlags(t *testing.T) {
 					return nil
 				})
 
-				var b strings.Builder
+				// The router renders help for undefined help flags, instead of the flag set printing its usage
+				var out, errOut strings.Builder
 				err := r.Run(clir.Context{
 					Args: []string{f},
-					Err:  &b,
+					Out:  &out,
+					Err:  &errOut,
 				})
 				is.NotError(t, err)
 				is.True(t, !called)
 				is.NotNil(t, v)
 				is.True(t, !*v)
+				is.True(t, strings.Contains(out.String(), "Flags:\n  -v  verbose output\n"))
+				is.Equal(t, "", errOut.String())
+			})
+		}
+	})
+
+	t.Run("prints usage for help flags not handled by the router", func(t *testing.T) {
+		var outerFS *flag.FlagSet
+		m := middleware.Flags(func(fs *flag.FlagSet) {
+			outerFS = fs
+			fs.Bool("v", false, "")
+		})
+
+		var called bool
+		runner := m(clir.RunnerFunc(func(ctx clir.Context) error {
+			called = true
+			return nil
+		}))
+
+		var b strings.Builder
+		err := runner.Run(clir.Context{
+			Args: []string{"-h"},
+			Err:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, !called)
+
+		var usageB strings.Builder
+		outerFS.SetOutput(&usageB)
+		outerFS.Usage()
+		is.Equal(t, usageB.String(), b.String())
+	})
+
+	t.Run("returns a validation error on invalid flag values", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Int("n", 0, "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-n", "notanumber"},
+			Err:  &b,
+		})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.Equal(t, `error while applying middleware: invalid value "notanumber" for flag -n: parse error`, err.Error())
+	})
+
+	t.Run("returns a usage error on unknown flags and prints usage", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Int("n", 0, "number of things")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-x"},
+			Err:  &b,
+		})
+		var usageErr *clir.UsageError
+		is.True(t, errors.As(err, &usageErr))
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.Equal(t, "flag provided but not defined: -x\nUsage:\n  -n int\n    \tnumber of things\n", b.String())
+	})
+
+	t.Run("can complete flag values with a completer", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.String("env", "", "environment")
+		}, middleware.WithCompleter("env", func(ctx clir.Context, prefix string) []clir.Candidate {
+			return []clir.Candidate{{Value: "production", Description: "Live"}, {Value: "staging"}}
+		})))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		candidates := r.Complete(clir.Context{}, []string{"-env", "p"})
+		is.Equal(t, 1, len(candidates))
+		is.Equal(t, "production", candidates[0].Value)
+		is.Equal(t, "Live", candidates[0].Description)
+
+		candidates = r.Complete(clir.Context{}, []string{"-env=s"})
+		is.Equal(t, 1, len(candidates))
+		is.Equal(t, "-env=staging", candidates[0].Value)
+	})
+
+	t.Run("describes flags in router help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Bool("v", false, "verbose output")
+			fs.String("name", "World", "`name` to greet")
+		}))
+
+		var called bool
+		r.RouteFunc("", func(ctx clir.Context) error {
+			called = true
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, !called)
+		is.True(t, strings.Contains(b.String(), "\nFlags:\n  -name name  name to greet (default \"World\")\n  -v          verbose output\n"))
+	})
+
+	t.Run("can bind flags to environment variables, with args taking precedence", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		var name, greeting *string
+		var v *bool
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			name = fs.String("name", "World", "")
+			greeting = fs.String("greeting", "Hello", "")
+			v = fs.Bool("v", false, "")
+		}, middleware.WithEnv("name", "NAME"), middleware.WithEnv("v", "VERBOSE")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Env: env(map[string]string{"NAME": "Env", "VERBOSE": "true", "GREETING": "Hi"}),
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Env", *name)
+		is.Equal(t, "Hello", *greeting)
+		is.True(t, *v)
+
+		err = r.Run(clir.Context{
+			Args: []string{"-name", "Args"},
+			Env:  env(map[string]string{"NAME": "Env"}),
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Args", *name)
+	})
+
+	t.Run("can bind flags to environment variables by prefix", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		var dryRun *bool
+		var name *string
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			dryRun = fs.Bool("dry-run", false, "")
+			name = fs.String("name", "", "")
+		}, middleware.WithEnvPrefix("MYTOOL"), middleware.WithEnv("name", "NAME")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Env: env(map[string]string{"MYTOOL_DRY_RUN": "true", "MYTOOL_NAME": "Prefix", "NAME": "Explicit"}),
+		})
+		is.NotError(t, err)
+		is.True(t, *dryRun)
+		is.Equal(t, "Explicit", *name)
+	})
+
+	t.Run("returns a validation error on invalid environment variable values", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Int("n", 0, "")
+		}, middleware.WithEnv("n", "N")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Env: env(map[string]string{"N": "notanumber"}),
+		})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.True(t, strings.HasSuffix(err.Error(), `invalid value "notanumber" for flag -n from environment variable N: parse error`))
+	})
+
+	t.Run("shows environment variables in router help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Bool("v", false, "verbose output")
+		}, middleware.WithEnvPrefix("MYTOOL")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "\nFlags:\n  -v  verbose output [$MYTOOL_V]\n"))
+	})
+}
+
+func TestArgSet(t *testing.T) {
+	t.Run("assigns args around a variadic argument", func(t *testing.T) {
+		tests := []struct {
+			args     []string
+			expected string
+		}{
+			{[]string{"a", "b", "c", "d"}, "a [b c] d []"},
+			{[]string{"a", "b", "c"}, "a [b] c []"},
+			{[]string{"a", "b"}, "a [] b []"},
+			{[]string{"a"}, "a [] dst []"},
+			{[]string{}, "first [] dst []"},
+			{[]string{"a", "--", "-b", "c"}, "a [-- -b] c []"},
+		}
+
+		for _, test := range tests {
+			t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+				var as middleware.ArgSet
+				first := as.String("first", "first", "")
+				src := as.Strings("src", "")
+				dst := as.String("dst", "dst", "")
+
+				err := as.Parse(test.args)
+				is.NotError(t, err)
+				is.Equal(t, test.expected, fmt.Sprint(*first, " ", *src, " ", *dst, " ", as.Args()))
+			})
+		}
+	})
+
+	t.Run("reports missing required and invalid variadic arguments", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.Ints("n", "")
+			as.String("dst", "", "")
+		}, middleware.Required("n", "dst")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{})
+		is.True(t, strings.HasSuffix(err.Error(), "invalid input:\n  - missing required argument n\n  - missing required argument dst"))
+
+		err = r.Run(clir.Context{Args: []string{"1", "x", "dst"}})
+		is.True(t, strings.HasSuffix(err.Error(), `invalid value "x" for argument n: strconv.ParseInt: parsing "x": invalid syntax`))
+
+		r = clir.NewRouter()
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.Ints("n", "")
+		}))
+		r.RouteFunc("", func(ctx clir.Context) error {
+			n, ok := middleware.Arg[[]int](ctx, "n")
+			is.True(t, ok)
+			ctx.Println(n)
+			return nil
+		})
+
+		var b strings.Builder
+		err = r.Run(clir.Context{Args: []string{"1", "2"}, Out: &b})
+		is.NotError(t, err)
+		err = r.Run(clir.Context{Out: &b})
+		is.NotError(t, err)
+		is.Equal(t, "[1 2]\n[]\n", b.String())
+	})
+
+	t.Run("reports extra args with NoExtraArgs", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.String("name", "", "")
+		}, middleware.NoExtraArgs()))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{Args: []string{"a", "b", "c"}})
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.True(t, strings.HasSuffix(err.Error(), "too many arguments: b c"))
+
+		err = r.Run(clir.Context{Args: []string{"a"}})
+		is.NotError(t, err)
+	})
+
+	t.Run("panics on more than one variadic argument", func(t *testing.T) {
+		defer func() {
+			is.Equal(t, "cannot have more than one variadic positional argument", recover())
+		}()
+
+		var as middleware.ArgSet
+		as.Strings("a", "")
+		as.Ints("b", "")
+	})
+
+	t.Run("has a synopsis for router help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		var synopsis string
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.Strings("src", "")
+			as.String("dst", "", "")
+			as.String("mode", "", "")
+			synopsis = as.Synopsis()
+		}, middleware.Required("src", "dst")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		is.Equal(t, "<src>... <dst> [mode]", synopsis)
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.HasPrefix(b.String(), "Usage:\n  middleware.test <src>... <dst> [mode]\n"))
+	})
+}
+
+func TestRequired(t *testing.T) {
+	t.Run("reports all missing and invalid flags at once", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.String("name", "", "")
+			fs.String("env", "", "")
+			fs.Int("n", 0, "")
+			fs.Bool("v", false, "")
+		}, middleware.Required("name", "env", "n"), middleware.WithEnv("n", "N")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Env: env(map[string]string{"N": "notanumber"}),
+		})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.Equal(t, 3, len(validationErr.Problems))
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.True(t, strings.HasSuffix(err.Error(), "invalid input:\n  - missing required flag -env\n  - invalid value \"notanumber\" for flag -n from environment variable N: parse error\n  - missing required flag -name"))
+
+		err = r.Run(clir.Context{
+			Args: []string{"-name", "Gopher"},
+			Env:  env(map[string]string{"N": "1", "ENV": "prod"}),
+		})
+		is.True(t, strings.HasSuffix(err.Error(), "missing required flag -env"))
+
+		err = r.Run(clir.Context{
+			Args: []string{"-name", "Gopher", "-env", "prod"},
+			Env:  env(map[string]string{"N": "1"}),
+		})
+		is.NotError(t, err)
+	})
+
+	t.Run("reports invalid flag values from the args with missing flags, after the invalid value", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Int("n", 0, "")
+			fs.String("name", "", "")
+			fs.String("env", "", "")
+		}, middleware.Required("n", "name", "env")))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"-n", "notanumber", "-name", "Gopher"},
+		})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.Equal(t, 2, len(validationErr.Problems))
+		is.True(t, strings.HasSuffix(err.Error(), "invalid input:\n  - invalid value \"notanumber\" for flag -n: parse error\n  - missing required flag -env"))
+	})
+
+	t.Run("reports invalid GNU flag values from the args with missing flags", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			fs.IntP("n", "n", 0, "")
+			fs.String("name", "", "")
+		}, middleware.Required("n", "name"), middleware.Interleaved()))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"a", "-n", "notanumber"},
+		})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.True(t, strings.HasSuffix(err.Error(), "invalid input:\n  - invalid value \"notanumber\" for flag -n: strconv.ParseInt: parsing \"notanumber\": invalid syntax\n  - missing required flag --name"))
+	})
+
+	t.Run("reports all missing and invalid args at once", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.Int("n", 0, "")
+			as.String("name", "", "")
+			as.String("env", "", "")
+		}, middleware.Required("n", "name", "env")))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"notanumber"},
+		})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.Equal(t, 3, len(validationErr.Problems))
+		is.True(t, strings.HasSuffix(err.Error(), "invalid input:\n  - invalid value \"notanumber\" for argument n: strconv.ParseInt: parsing \"notanumber\": invalid syntax\n  - missing required argument name\n  - missing required argument env"))
+
+		err = r.Run(clir.Context{
+			Args: []string{"1", "Gopher", "prod"},
+		})
+		is.NotError(t, err)
+	})
+
+	t.Run("shows required inputs in router help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			fs.String("name", "", "name to greet")
+		}, middleware.Required("name")))
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.String("env", "", "environment")
+		}, middleware.Required("env")))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "\nArguments:\n  env  environment (required)\n"))
+		is.True(t, strings.Contains(b.String(), "\nFlags:\n  --name string  name to greet (required)\n"))
+	})
+}
+
+func TestFlag(t *testing.T) {
+	t.Run("gets flag values parsed in this run, without leaking values between runs", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Bool("v", false, "")
+			fs.String("name", "World", "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			v, ok := middleware.Flag[bool](ctx, "v")
+			is.True(t, ok)
+			name, ok := middleware.Flag[string](ctx, "name")
+			is.True(t, ok)
+			ctx.Println(v, name)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{Args: []string{"-v", "-name", "Gopher"}, Out: &b})
+		is.NotError(t, err)
+		err = r.Run(clir.Context{Args: []string{}, Out: &b})
+		is.NotError(t, err)
+		is.Equal(t, "true Gopher\nfalse World\n", b.String())
+	})
+
+	t.Run("gets flags from parent routers, and the flag value itself for other types", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Func("level", "", func(string) error { return nil })
+		}))
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+				fs.Int("n", 1, "")
+			}))
+
+			r.RouteFunc("", func(ctx clir.Context) error {
+				_, ok := middleware.Flag[flag.Value](ctx, "level")
+				is.True(t, ok)
+				n, ok := middleware.Flag[int](ctx, "n")
+				is.True(t, ok)
+				is.Equal(t, 3, n)
+				_, ok = middleware.Flag[string](ctx, "n")
+				is.True(t, !ok)
+				_, ok = middleware.Flag[int](ctx, "doesnotexist")
+				is.True(t, !ok)
+				return nil
+			})
+		})
+
+		err := r.Run(clir.Context{Args: []string{"-level", "high", "dance", "-n", "3"}})
+		is.NotError(t, err)
+	})
+
+	t.Run("can run concurrently", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Int("n", 0, "")
+		}))
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.Int("m", 0, "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			n, _ := middleware.Flag[int](ctx, "n")
+			m, _ := middleware.Arg[int](ctx, "m")
+			if n != m {
+				return errors.New("flag and arg differ")
+			}
+			return nil
+		})
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for i := range 100 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- r.Run(clir.Context{Args: []string{"-n", strconv.Itoa(i), strconv.Itoa(i)}})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			is.NotError(t, err)
+		}
+	})
+}
+
+func TestArg(t *testing.T) {
+	t.Run("gets arg values parsed in this run", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.String("name", "World", "")
+			as.Float64("height", 1.5, "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			name, ok := middleware.Arg[string](ctx, "name")
+			is.True(t, ok)
+			height, ok := middleware.Arg[float64](ctx, "height")
+			is.True(t, ok)
+			ctx.Println(name, height)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{Args: []string{"Gopher", "2"}, Out: &b})
+		is.NotError(t, err)
+		err = r.Run(clir.Context{Args: []string{}, Out: &b})
+		is.NotError(t, err)
+		is.Equal(t, "Gopher 2\nWorld 1.5\n", b.String())
+	})
+}
+
+func TestInterleaved(t *testing.T) {
+	t.Run("parses flags anywhere in the args, also after branch names", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Bool("v", false, "")
+			fs.String("name", "", "")
+		}, middleware.Interleaved()))
+
+		r.Branch("deploy", func(r *clir.Router) {
+			r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+				fs.Int("n", 0, "")
+			}))
+
+			r.Use(middleware.Args(func(as *middleware.ArgSet) {
+				as.String("env", "", "")
+			}))
+
+			r.RouteFunc("*", func(ctx clir.Context) error {
+				v, _ := middleware.Flag[bool](ctx, "v")
+				name, _ := middleware.Flag[string](ctx, "name")
+				n, _ := middleware.Flag[int](ctx, "n")
+				env, _ := middleware.Arg[string](ctx, "env")
+				ctx.Println(v, name, n, env, ctx.Args)
+				return nil
+			})
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"deploy", "-n", "3", "-v", "prod", "--name=Gopher", "extra"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "true Gopher 3 prod [extra]\n", b.String())
+	})
 
-				var usageB strings.Builder
-				outerFS.SetOutput(&usageB)
-				outerFS.Usage()
-				is.Equal(t, usageB.String(), b.String())
+	t.Run("stops parsing at a double dash", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Bool("v", false, "")
+		}, middleware.Interleaved()))
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.String("first", "", "")
+			as.String("second", "", "")
+		}))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			v, _ := middleware.Flag[bool](ctx, "v")
+			first, _ := middleware.Arg[string](ctx, "first")
+			second, _ := middleware.Arg[string](ctx, "second")
+			ctx.Println(v, first, second, ctx.Args)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"a", "--", "-v", "b"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "false a -v [b]\n", b.String())
+	})
+
+	t.Run("keeps a literal double dash after the flags if not interleaved", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.Bool("i", false, "")
+		}))
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.String("pattern", "", "")
+			as.String("file", "", "")
+		}))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			pattern, _ := middleware.Arg[string](ctx, "pattern")
+			file, _ := middleware.Arg[string](ctx, "file")
+			ctx.Println(pattern, file)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-i", "--", "--", "file"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "-- file\n", b.String())
+	})
+
+	t.Run("skips parent flags after branch names in router help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.String("name", "", "")
+		}, middleware.Interleaved()))
+
+		r.Branch("deploy", func(r *clir.Router) {
+			r.RouteFunc("prod", func(ctx clir.Context) error {
+				return nil
+			}, clir.WithSummary("Deploy to production"))
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"deploy", "-name", "prod", "-h"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "deploy <command>"))
+		is.True(t, strings.Contains(b.String(), "Deploy to production"))
+	})
+
+	t.Run("leaves undefined help flags after positional args for the routes", func(t *testing.T) {
+		for name, m := range map[string]clir.Middleware{
+			"flags": middleware.Flags(func(fs *flag.FlagSet) {
+				fs.Bool("v", false, "")
+			}, middleware.Interleaved()),
+			"gnu flags": middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+				fs.BoolP("verbose", "v", false, "")
+			}, middleware.Interleaved()),
+		} {
+			t.Run(name, func(t *testing.T) {
+				r := clir.NewRouter()
+
+				r.Use(m)
+
+				r.RouteFunc("greet", func(ctx clir.Context) error {
+					ctx.Println("greet", ctx.Args)
+					return nil
+				})
+
+				r.NotFoundFunc(func(ctx clir.Context) error {
+					ctx.Println("plugin", ctx.Args)
+					return nil
+				})
+
+				var out, errOut strings.Builder
+				err := r.Run(clir.Context{
+					Args: []string{"greet", "Bob", "-h"},
+					Out:  &out,
+					Err:  &errOut,
+				})
+				is.NotError(t, err)
+
+				err = r.Run(clir.Context{
+					Args: []string{"plugin", "-v", "--help"},
+					Out:  &out,
+					Err:  &errOut,
+				})
+				is.NotError(t, err)
+				is.Equal(t, "greet [Bob -h]\nplugin [plugin --help]\n", out.String())
+				is.Equal(t, "", errOut.String())
 			})
 		}
 	})
+
+	t.Run("returns a usage error on a missing flag value", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			fs.String("name", "", "")
+		}, middleware.Interleaved()))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"a", "-name"},
+			Err:  &b,
+		})
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.True(t, strings.HasSuffix(err.Error(), "flag needs an argument: -name"))
+	})
+}
+
+// env returns a function for [clir.Context.Env] which looks up environment variables in the map.
+func env(m map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := m[key]
+		return v, ok
+	}
 }
 
 func ExampleFlags() {
@@ -337,4 +1111,74 @@ func TestArgs(t *testing.T) {
 		is.NotNil(t, command)
 		is.Equal(t, "job", *command)
 	})
+
+	t.Run("returns a validation error on invalid args", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.Int("count", 1, "")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"many"},
+		})
+		var validationErr *clir.ValidationError
+		is.True(t, errors.As(err, &validationErr))
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.Equal(t, `error while applying middleware: invalid value "many" for argument count: strconv.ParseInt: parsing "many": invalid syntax`, err.Error())
+	})
+
+	t.Run("can complete args with a completer", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Branch("deploy", func(r *clir.Router) {
+			r.Use(middleware.Args(func(as *middleware.ArgSet) {
+				as.String("env", "", "environment")
+				as.String("version", "", "version")
+			}, middleware.WithCompleter("version", func(ctx clir.Context, prefix string) []clir.Candidate {
+				// The args before the one being completed are available
+				if ctx.Args[len(ctx.Args)-1] == "staging" {
+					return []clir.Candidate{{Value: "v2"}}
+				}
+				return []clir.Candidate{{Value: "v1"}}
+			})))
+
+			r.RouteFunc("", func(ctx clir.Context) error {
+				return nil
+			})
+		})
+
+		candidates := r.Complete(clir.Context{}, []string{"deploy", ""})
+		is.Equal(t, 0, len(candidates))
+
+		candidates = r.Complete(clir.Context{}, []string{"deploy", "staging", ""})
+		is.Equal(t, 1, len(candidates))
+		is.Equal(t, "v2", candidates[0].Value)
+	})
+
+	t.Run("describes args in router help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			as.String("name", "World", "name to greet")
+			as.Int("count", 1, "number of times to greet")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"--help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), " [name] [count]\n"))
+		is.True(t, strings.Contains(b.String(), "\nArguments:\n  name   name to greet (default \"World\")\n  count  number of times to greet (default \"1\")\n"))
+	})
 }
@@ -0,0 +1,655 @@
+package middleware
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VarSet is satisfied by [flag.FlagSet], [GNUFlagSet], and [ArgSet],
+// to define flags and positional arguments with the value types in this package.
+type VarSet interface {
+	Var(value flag.Value, name string, usage string)
+}
+
+var (
+	_ VarSet = (*flag.FlagSet)(nil)
+	_ VarSet = (*GNUFlagSet)(nil)
+	_ VarSet = (*ArgSet)(nil)
+)
+
+// Duration defines a [time.Duration] input, parsed with [time.ParseDuration].
+func Duration(vs VarSet, name string, value time.Duration, usage string) *time.Duration {
+	p := new(time.Duration)
+	DurationVar(vs, p, name, value, usage)
+	return p
+}
+
+// DurationVar defines a [time.Duration] input with a pointer.
+func DurationVar(vs VarSet, p *time.Duration, name string, value time.Duration, usage string) {
+	*p = value
+	vs.Var((*durationValue)(p), name, usage)
+}
+
+type durationValue time.Duration
+
+func (d *durationValue) Set(val string) error {
+	v, err := time.ParseDuration(val)
+	if err != nil {
+		return err
+	}
+	*d = durationValue(v)
+	return nil
+}
+
+func (d *durationValue) Get() any { return time.Duration(*d) }
+
+func (d *durationValue) String() string { return time.Duration(*d).String() }
+
+func (d *durationValue) typeName() string { return "duration" }
+
+// Int64 defines an int64 input.
+func Int64(vs VarSet, name string, value int64, usage string) *int64 {
+	p := new(int64)
+	Int64Var(vs, p, name, value, usage)
+	return p
+}
+
+// Int64Var defines an int64 input with a pointer.
+func Int64Var(vs VarSet, p *int64, name string, value int64, usage string) {
+	*p = value
+	vs.Var((*int64Value)(p), name, usage)
+}
+
+type int64Value int64
+
+func (i *int64Value) Set(val string) error {
+	v, err := strconv.ParseInt(val, 0, 64)
+	if err != nil {
+		return err
+	}
+	*i = int64Value(v)
+	return nil
+}
+
+func (i *int64Value) Get() any { return int64(*i) }
+
+func (i *int64Value) String() string { return strconv.FormatInt(int64(*i), 10) }
+
+func (i *int64Value) typeName() string { return "int" }
+
+// Uint defines a uint input.
+func Uint(vs VarSet, name string, value uint, usage string) *uint {
+	p := new(uint)
+	UintVar(vs, p, name, value, usage)
+	return p
+}
+
+// UintVar defines a uint input with a pointer.
+func UintVar(vs VarSet, p *uint, name string, value uint, usage string) {
+	*p = value
+	vs.Var((*uintValue)(p), name, usage)
+}
+
+type uintValue uint
+
+func (u *uintValue) Set(val string) error {
+	v, err := strconv.ParseUint(val, 0, strconv.IntSize)
+	if err != nil {
+		return err
+	}
+	*u = uintValue(v)
+	return nil
+}
+
+func (u *uintValue) Get() any { return uint(*u) }
+
+func (u *uintValue) String() string { return strconv.FormatUint(uint64(*u), 10) }
+
+func (u *uintValue) typeName() string { return "uint" }
+
+// Uint64 defines a uint64 input.
+func Uint64(vs VarSet, name string, value uint64, usage string) *uint64 {
+	p := new(uint64)
+	Uint64Var(vs, p, name, value, usage)
+	return p
+}
+
+// Uint64Var defines a uint64 input with a pointer.
+func Uint64Var(vs VarSet, p *uint64, name string, value uint64, usage string) {
+	*p = value
+	vs.Var((*uint64Value)(p), name, usage)
+}
+
+type uint64Value uint64
+
+func (u *uint64Value) Set(val string) error {
+	v, err := strconv.ParseUint(val, 0, 64)
+	if err != nil {
+		return err
+	}
+	*u = uint64Value(v)
+	return nil
+}
+
+func (u *uint64Value) Get() any { return uint64(*u) }
+
+func (u *uint64Value) String() string { return strconv.FormatUint(uint64(*u), 10) }
+
+func (u *uint64Value) typeName() string { return "uint" }
+
+// Time defines a [time.Time] input, parsed with [time.Parse] and the layout, like [time.RFC3339] or [time.DateOnly].
+func Time(vs VarSet, name string, layout string, value time.Time, usage string) *time.Time {
+	p := new(time.Time)
+	TimeVar(vs, p, name, layout, value, usage)
+	return p
+}
+
+// TimeVar defines a [time.Time] input with a pointer.
+func TimeVar(vs VarSet, p *time.Time, name string, layout string, value time.Time, usage string) {
+	*p = value
+	vs.Var(&timeValue{p: p, def: value, layout: layout}, name, usage)
+}
+
+type timeValue struct {
+	p      *time.Time
+	def    time.Time
+	layout string
+}
+
+func (t *timeValue) Set(val string) error {
+	v, err := time.Parse(t.layout, val)
+	if err != nil {
+		return err
+	}
+	*t.p = v
+	return nil
+}
+
+func (t *timeValue) Get() any { return *t.p }
+
+func (t *timeValue) String() string {
+	if t.p == nil || t.p.IsZero() {
+		return ""
+	}
+	return t.p.Format(t.layout)
+}
+
+func (t *timeValue) reset() { *t.p = t.def }
+
+func (t *timeValue) typeName() string { return "time" }
+
+// URL defines a [url.URL] input, parsed with [url.Parse].
+// The default value is parsed the same way, and it panics if it's invalid.
+func URL(vs VarSet, name string, value string, usage string) *url.URL {
+	p := new(url.URL)
+	URLVar(vs, p, name, value, usage)
+	return p
+}
+
+// URLVar defines a [url.URL] input with a pointer.
+func URLVar(vs VarSet, p *url.URL, name string, value string, usage string) {
+	v := (*urlValue)(p)
+	if err := v.Set(value); err != nil {
+		panic(fmt.Sprintf("invalid default value %q for %v: %v", value, name, err))
+	}
+	vs.Var(v, name, usage)
+}
+
+type urlValue url.URL
+
+func (u *urlValue) Set(val string) error {
+	v, err := url.Parse(val)
+	if err != nil {
+		return err
+	}
+	*u = urlValue(*v)
+	return nil
+}
+
+func (u *urlValue) Get() any { return (*url.URL)(u) }
+
+func (u *urlValue) String() string { return (*url.URL)(u).String() }
+
+func (u *urlValue) typeName() string { return "url" }
+
+// Addr defines a [netip.Addr] input, parsed with [netip.ParseAddr].
+func Addr(vs VarSet, name string, value netip.Addr, usage string) *netip.Addr {
+	p := new(netip.Addr)
+	AddrVar(vs, p, name, value, usage)
+	return p
+}
+
+// AddrVar defines a [netip.Addr] input with a pointer.
+func AddrVar(vs VarSet, p *netip.Addr, name string, value netip.Addr, usage string) {
+	*p = value
+	vs.Var((*addrValue)(p), name, usage)
+}
+
+type addrValue netip.Addr
+
+func (a *addrValue) Set(val string) error {
+	if val == "" {
+		*a = addrValue(netip.Addr{})
+		return nil
+	}
+	v, err := netip.ParseAddr(val)
+	if err != nil {
+		return err
+	}
+	*a = addrValue(v)
+	return nil
+}
+
+func (a *addrValue) Get() any { return netip.Addr(*a) }
+
+func (a *addrValue) String() string {
+	if !netip.Addr(*a).IsValid() {
+		return ""
+	}
+	return netip.Addr(*a).String()
+}
+
+func (a *addrValue) typeName() string { return "addr" }
+
+// AddrPort defines a [netip.AddrPort] input, parsed with [netip.ParseAddrPort].
+func AddrPort(vs VarSet, name string, value netip.AddrPort, usage string) *netip.AddrPort {
+	p := new(netip.AddrPort)
+	AddrPortVar(vs, p, name, value, usage)
+	return p
+}
+
+// AddrPortVar defines a [netip.AddrPort] input with a pointer.
+func AddrPortVar(vs VarSet, p *netip.AddrPort, name string, value netip.AddrPort, usage string) {
+	*p = value
+	vs.Var((*addrPortValue)(p), name, usage)
+}
+
+type addrPortValue netip.AddrPort
+
+func (a *addrPortValue) Set(val string) error {
+	if val == "" {
+		*a = addrPortValue(netip.AddrPort{})
+		return nil
+	}
+	v, err := netip.ParseAddrPort(val)
+	if err != nil {
+		return err
+	}
+	*a = addrPortValue(v)
+	return nil
+}
+
+func (a *addrPortValue) Get() any { return netip.AddrPort(*a) }
+
+func (a *addrPortValue) String() string {
+	if !netip.AddrPort(*a).IsValid() {
+		return ""
+	}
+	return netip.AddrPort(*a).String()
+}
+
+func (a *addrPortValue) typeName() string { return "addrport" }
+
+// ByteSize is a number of bytes, like "512", "10MiB", or "1.5GB".
+// Units are B, KB, MB, GB, TB, and PB in powers of 1000, and KiB, MiB, GiB, TiB, and PiB in powers of 1024,
+// case-insensitively.
+type ByteSize uint64
+
+// byteUnits from largest to smallest, with the binary unit first for each power.
+var byteUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"PiB", 1 << 50}, {"PB", 1e15},
+	{"TiB", 1 << 40}, {"TB", 1e12},
+	{"GiB", 1 << 30}, {"GB", 1e9},
+	{"MiB", 1 << 20}, {"MB", 1e6},
+	{"KiB", 1 << 10}, {"KB", 1e3},
+	{"B", 1},
+}
+
+// ParseByteSize parses a [ByteSize] like "10MiB".
+func ParseByteSize(s string) (ByteSize, error) {
+	number := strings.TrimRightFunc(s, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	})
+	unit := strings.TrimSpace(s[len(number):])
+	number = strings.TrimSpace(number)
+
+	size := ByteSize(1)
+	if unit != "" {
+		size = 0
+		for _, u := range byteUnits {
+			if strings.EqualFold(u.name, unit) {
+				size = u.size
+				break
+			}
+		}
+		if size == 0 {
+			return 0, fmt.Errorf("invalid byte size unit %q", unit)
+		}
+	}
+
+	if v, err := strconv.ParseUint(number, 10, 64); err == nil {
+		if v > uint64(^ByteSize(0)/size) {
+			return 0, fmt.Errorf("byte size %q out of range", s)
+		}
+		return ByteSize(v) * size, nil
+	}
+
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	if v*float64(size) >= float64(^ByteSize(0)) {
+		return 0, fmt.Errorf("byte size %q out of range", s)
+	}
+	return ByteSize(v * float64(size)), nil
+}
+
+// String in the largest unit the size is a whole number of, like "10MiB" or "1500B".
+func (b ByteSize) String() string {
+	for _, u := range byteUnits {
+		if b >= u.size && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return "0B"
+}
+
+// Bytes defines a [ByteSize] input, parsed with [ParseByteSize].
+func Bytes(vs VarSet, name string, value ByteSize, usage string) *ByteSize {
+	p := new(ByteSize)
+	BytesVar(vs, p, name, value, usage)
+	return p
+}
+
+// BytesVar defines a [ByteSize] input with a pointer.
+func BytesVar(vs VarSet, p *ByteSize, name string, value ByteSize, usage string) {
+	*p = value
+	vs.Var((*byteSizeValue)(p), name, usage)
+}
+
+type byteSizeValue ByteSize
+
+func (b *byteSizeValue) Set(val string) error {
+	v, err := ParseByteSize(val)
+	if err != nil {
+		return err
+	}
+	*b = byteSizeValue(v)
+	return nil
+}
+
+func (b *byteSizeValue) Get() any { return ByteSize(*b) }
+
+func (b *byteSizeValue) String() string { return ByteSize(*b).String() }
+
+func (b *byteSizeValue) typeName() string { return "size" }
+
+// ExistingPath defines a string input which must be the path of an existing file or directory.
+// Relative paths are relative to [clir.Context.Dir] when parsed by [Flags], [GNUFlags], [Args], or [Bind],
+// and to the working directory of the process otherwise. The value is the path as given, so open it with [clir.Context.Abs].
+func ExistingPath(vs VarSet, name string, value string, usage string) *string {
+	p := new(string)
+	ExistingPathVar(vs, p, name, value, usage)
+	return p
+}
+
+// ExistingPathVar defines an existing path input with a pointer.
+func ExistingPathVar(vs VarSet, p *string, name string, value string, usage string) {
+	*p = value
+	vs.Var(&existingPathValue{p: p, def: value}, name, usage)
+}
+
+type existingPathValue struct {
+	p   *string
+	def string
+	abs func(path string) string
+}
+
+func (e *existingPathValue) Set(val string) error {
+	path := val
+	if e.abs != nil {
+		path = e.abs(val)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("path %q does not exist", val)
+		}
+		return err
+	}
+	*e.p = val
+	return nil
+}
+
+func (e *existingPathValue) Get() any { return *e.p }
+
+func (e *existingPathValue) String() string {
+	if e.p == nil {
+		return ""
+	}
+	return *e.p
+}
+
+func (e *existingPathValue) reset() { *e.p = e.def }
+
+func (e *existingPathValue) setAbs(abs func(path string) string) { e.abs = abs }
+
+func (e *existingPathValue) typeName() string { return "path" }
+
+// StringSlice defines a []string input, with values separated by commas, like "a,b", or given repeatedly for flags.
+// The first value replaces the default, and later values are appended.
+func StringSlice(vs VarSet, name string, value []string, usage string) *[]string {
+	p := new([]string)
+	StringSliceVar(vs, p, name, value, usage)
+	return p
+}
+
+// StringSliceVar defines a []string input with a pointer.
+func StringSliceVar(vs VarSet, p *[]string, name string, value []string, usage string) {
+	*p = slices.Clone(value)
+	vs.Var(&stringSliceValue{p: p, def: slices.Clone(value)}, name, usage)
+}
+
+type stringSliceValue struct {
+	p       *[]string
+	def     []string
+	changed bool
+}
+
+func (s *stringSliceValue) Set(val string) error {
+	if !s.changed {
+		*s.p = nil
+		s.changed = true
+	}
+	if val != "" {
+		*s.p = append(*s.p, strings.Split(val, ",")...)
+	}
+	return nil
+}
+
+func (s *stringSliceValue) Get() any { return *s.p }
+
+func (s *stringSliceValue) String() string {
+	if s.p == nil {
+		return ""
+	}
+	return strings.Join(*s.p, ",")
+}
+
+func (s *stringSliceValue) reset() {
+	*s.p = slices.Clone(s.def)
+	s.changed = false
+}
+
+func (s *stringSliceValue) typeName() string { return "strings" }
+
+// StringMap defines a map[string]string input, with key=value pairs separated by commas, like "a=1,b=2",
+// or given repeatedly for flags. The first value replaces the default, and later values are added.
+func StringMap(vs VarSet, name string, value map[string]string, usage string) *map[string]string {
+	p := new(map[string]string)
+	StringMapVar(vs, p, name, value, usage)
+	return p
+}
+
+// StringMapVar defines a map[string]string input with a pointer.
+func StringMapVar(vs VarSet, p *map[string]string, name string, value map[string]string, usage string) {
+	*p = maps.Clone(value)
+	vs.Var(&stringMapValue{p: p, def: maps.Clone(value)}, name, usage)
+}
+
+type stringMapValue struct {
+	p       *map[string]string
+	def     map[string]string
+	changed bool
+}
+
+func (s *stringMapValue) Set(val string) error {
+	if !s.changed {
+		*s.p = map[string]string{}
+		s.changed = true
+	}
+	if val == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(val, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		(*s.p)[k] = v
+	}
+	return nil
+}
+
+func (s *stringMapValue) Get() any { return *s.p }
+
+func (s *stringMapValue) String() string {
+	if s.p == nil {
+		return ""
+	}
+	var pairs []string
+	for _, k := range slices.Sorted(maps.Keys(*s.p)) {
+		pairs = append(pairs, k+"="+(*s.p)[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (s *stringMapValue) reset() {
+	*s.p = maps.Clone(s.def)
+	s.changed = false
+}
+
+func (s *stringMapValue) typeName() string { return "key=value" }
+
+// TextVar defines an input with a value satisfying [encoding.TextUnmarshaler], like [flag.FlagSet.TextVar].
+// The pointer p must point to a value of the same type as value, which is the default.
+func TextVar(vs VarSet, p encoding.TextUnmarshaler, name string, value encoding.TextMarshaler, usage string) {
+	ptrVal := reflect.ValueOf(p)
+	if ptrVal.Kind() != reflect.Ptr {
+		panic("variable value type must be a pointer")
+	}
+	defVal := reflect.ValueOf(value)
+	if defVal.Kind() == reflect.Ptr {
+		defVal = defVal.Elem()
+	}
+	if defVal.Type() != ptrVal.Type().Elem() {
+		panic(fmt.Sprintf("default type does not match variable type: %v != %v", defVal.Type(), ptrVal.Type().Elem()))
+	}
+	ptrVal.Elem().Set(defVal)
+	vs.Var(textValue{p: p}, name, usage)
+}
+
+type textValue struct {
+	p encoding.TextUnmarshaler
+}
+
+func (t textValue) Set(val string) error {
+	return t.p.UnmarshalText([]byte(val))
+}
+
+func (t textValue) Get() any { return t.p }
+
+func (t textValue) String() string {
+	if m, ok := t.p.(encoding.TextMarshaler); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	return ""
+}
+
+func (t textValue) typeName() string { return "value" }
+
+// Choice defines a string input which must be one of the choices.
+// The choices are shown in help output and used for completion.
+func Choice(vs VarSet, name string, value string, choices []string, usage string) *string {
+	p := new(string)
+	ChoiceVar(vs, p, name, value, choices, usage)
+	return p
+}
+
+// ChoiceVar defines a choice input with a pointer.
+func ChoiceVar(vs VarSet, p *string, name string, value string, choices []string, usage string) {
+	*p = value
+	vs.Var(&choiceValue{p: p, def: value, choices: slices.Clone(choices)}, name, usage)
+}
+
+// ChoiceFold is like [Choice], but matches the choices case-insensitively.
+// The value is set to the matching choice as given in choices.
+func ChoiceFold(vs VarSet, name string, value string, choices []string, usage string) *string {
+	p := new(string)
+	ChoiceFoldVar(vs, p, name, value, choices, usage)
+	return p
+}
+
+// ChoiceFoldVar defines a case-insensitive choice input with a pointer.
+func ChoiceFoldVar(vs VarSet, p *string, name string, value string, choices []string, usage string) {
+	*p = value
+	vs.Var(&choiceValue{p: p, def: value, choices: slices.Clone(choices), fold: true}, name, usage)
+}
+
+type choiceValue struct {
+	p       *string
+	def     string
+	choices []string
+	fold    bool
+}
+
+func (c *choiceValue) Set(val string) error {
+	for _, choice := range c.choices {
+		if choice == val || (c.fold && strings.EqualFold(choice, val)) {
+			*c.p = choice
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %v", strings.Join(c.choices, ", "))
+}
+
+func (c *choiceValue) Get() any { return *c.p }
+
+func (c *choiceValue) String() string {
+	if c.p == nil {
+		return ""
+	}
+	return *c.p
+}
+
+// Choices for [clir.Input.Choices].
+func (c *choiceValue) Choices() []string { return slices.Clone(c.choices) }
+
+func (c *choiceValue) reset() { *c.p = c.def }
+
+func (c *choiceValue) typeName() string { return "string" }
@@ -0,0 +1,306 @@
+package middleware_test
+
+import (
+	"flag"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maragu.dev/is"
+
+	"maragu.dev/clir"
+	"maragu.dev/clir/middleware"
+)
+
+func TestValues(t *testing.T) {
+	t.Run("can parse flags of all types", func(t *testing.T) {
+		dir := t.TempDir()
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		d := middleware.Duration(fs, "timeout", time.Second, "")
+		i64 := middleware.Int64(fs, "offset", 0, "")
+		u := middleware.Uint(fs, "count", 0, "")
+		u64 := middleware.Uint64(fs, "max", 0, "")
+		ti := middleware.Time(fs, "since", time.DateOnly, time.Time{}, "")
+		link := middleware.URL(fs, "url", "", "")
+		addr := middleware.Addr(fs, "ip", netip.Addr{}, "")
+		addrPort := middleware.AddrPort(fs, "listen", netip.AddrPort{}, "")
+		size := middleware.Bytes(fs, "size", 0, "")
+		path := middleware.ExistingPath(fs, "dir", "", "")
+		tags := middleware.StringSlice(fs, "tag", []string{"default"}, "")
+		labels := middleware.StringMap(fs, "label", nil, "")
+		var level slogLevel
+		middleware.TextVar(fs, &level, "level", slogLevel("info"), "")
+
+		err := fs.Parse([]string{
+			"-timeout", "1m30s", "-offset", "-42", "-count", "7", "-max", "18446744073709551615",
+			"-since", "2024-01-02", "-url", "https://example.com/a?b=c", "-ip", "::1", "-listen", "127.0.0.1:8080",
+			"-size", "10MiB", "-dir", dir, "-tag", "a,b", "-tag", "c", "-label", "a=1,b=2", "-label", "c=3",
+			"-level", "DEBUG",
+		})
+		is.NotError(t, err)
+		is.Equal(t, 90*time.Second, *d)
+		is.Equal(t, int64(-42), *i64)
+		is.Equal(t, uint(7), *u)
+		is.Equal(t, uint64(18446744073709551615), *u64)
+		is.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), *ti)
+		is.Equal(t, "https://example.com/a?b=c", link.String())
+		is.Equal(t, "example.com", link.Host)
+		is.Equal(t, netip.MustParseAddr("::1"), *addr)
+		is.Equal(t, netip.MustParseAddrPort("127.0.0.1:8080"), *addrPort)
+		is.Equal(t, middleware.ByteSize(10<<20), *size)
+		is.Equal(t, dir, *path)
+		is.Equal(t, "a,b,c", strings.Join(*tags, ","))
+		is.Equal(t, 3, len(*labels))
+		is.Equal(t, "2", (*labels)["b"])
+		is.Equal(t, slogLevel("debug"), level)
+	})
+
+	t.Run("keeps defaults when not set", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		d := middleware.Duration(fs, "timeout", time.Second, "")
+		link := middleware.URL(fs, "url", "https://example.com", "")
+		tags := middleware.StringSlice(fs, "tag", []string{"a", "b"}, "")
+		labels := middleware.StringMap(fs, "label", map[string]string{"a": "1"}, "")
+
+		err := fs.Parse(nil)
+		is.NotError(t, err)
+		is.Equal(t, time.Second, *d)
+		is.Equal(t, "https://example.com", link.String())
+		is.Equal(t, "a,b", strings.Join(*tags, ","))
+		is.Equal(t, "1", (*labels)["a"])
+		is.Equal(t, "a,b", fs.Lookup("tag").DefValue)
+		is.Equal(t, "a=1", fs.Lookup("label").DefValue)
+	})
+
+	t.Run("errors on invalid values", func(t *testing.T) {
+		tests := []struct {
+			define func(vs middleware.VarSet)
+			arg    string
+			err    string
+		}{
+			{func(vs middleware.VarSet) { middleware.Duration(vs, "x", 0, "") }, "1 minute", `time: unknown unit " minute" in duration "1 minute"`},
+			{func(vs middleware.VarSet) { middleware.Uint(vs, "x", 0, "") }, "-1", `strconv.ParseUint: parsing "-1": invalid syntax`},
+			{func(vs middleware.VarSet) { middleware.Time(vs, "x", time.DateOnly, time.Time{}, "") }, "yesterday", `parsing time "yesterday" as "2006-01-02": cannot parse "yesterday" as "2006"`},
+			{func(vs middleware.VarSet) { middleware.Addr(vs, "x", netip.Addr{}, "") }, "localhost", `ParseAddr("localhost"): unable to parse IP`},
+			{func(vs middleware.VarSet) { middleware.Bytes(vs, "x", 0, "") }, "10XB", `invalid byte size unit "XB"`},
+			{func(vs middleware.VarSet) { middleware.ExistingPath(vs, "x", "", "") }, "does-not-exist", `path "does-not-exist" does not exist`},
+			{func(vs middleware.VarSet) { middleware.StringMap(vs, "x", nil, "") }, "a", `"a" is not a key=value pair`},
+		}
+
+		for _, test := range tests {
+			t.Run(test.arg, func(t *testing.T) {
+				var a middleware.ArgSet
+				test.define(&a)
+				err := a.Parse([]string{test.arg})
+				is.True(t, err != nil)
+				is.Equal(t, `invalid value "`+test.arg+`" for argument x: `+test.err, err.Error())
+			})
+		}
+	})
+
+	t.Run("can be used as positional arguments and looked up by type", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			middleware.Duration(as, "timeout", 0, "")
+			middleware.StringSlice(as, "tags", nil, "")
+		}))
+
+		var timeout time.Duration
+		var tags []string
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			timeout, _ = middleware.Arg[time.Duration](ctx, "timeout")
+			tags, _ = middleware.Arg[[]string](ctx, "tags")
+			return nil
+		})
+
+		err := r.Run(clir.Context{Args: []string{"5s", "a,b"}})
+		is.NotError(t, err)
+		is.Equal(t, 5*time.Second, timeout)
+		is.Equal(t, "a,b", strings.Join(tags, ","))
+	})
+
+	t.Run("checks existing paths relative to the context dir", func(t *testing.T) {
+		dir := t.TempDir()
+		is.NotError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), nil, 0600))
+
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			middleware.ExistingPath(fs, "config", "", "")
+		}))
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			middleware.ExistingPath(as, "path", "", "")
+		}))
+
+		var config, path string
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			config, _ = middleware.Flag[string](ctx, "config")
+			path, _ = middleware.Arg[string](ctx, "path")
+			return nil
+		})
+
+		err := r.Run(clir.Context{Args: []string{"-config", "config.yaml", "config.yaml"}, Dir: dir})
+		is.NotError(t, err)
+		is.Equal(t, "config.yaml", config)
+		is.Equal(t, "config.yaml", path)
+
+		err = r.Run(clir.Context{Args: []string{"-config", "config.yaml"}, Dir: t.TempDir()})
+		is.True(t, err != nil)
+		is.True(t, strings.HasSuffix(err.Error(), `invalid value "config.yaml" for flag -config: path "config.yaml" does not exist`))
+	})
+
+	t.Run("resets positional arguments to defaults between parses", func(t *testing.T) {
+		dir := t.TempDir()
+		var a middleware.ArgSet
+		tags := middleware.StringSlice(&a, "tags", []string{"x"}, "")
+		path := middleware.ExistingPath(&a, "path", "", "")
+
+		is.NotError(t, a.Parse([]string{"a,b", dir}))
+		is.Equal(t, "a,b", strings.Join(*tags, ","))
+		is.Equal(t, dir, *path)
+
+		is.NotError(t, a.Parse(nil))
+		is.Equal(t, "x", strings.Join(*tags, ","))
+		is.Equal(t, "", *path)
+	})
+
+	t.Run("has type names in help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+			middleware.Duration(fs, "timeout", time.Second, "how long to wait")
+			middleware.Bytes(fs, "size", 10<<20, "max size")
+		}))
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"--help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "  -size size         max size (default \"10MiB\")\n"))
+		is.True(t, strings.Contains(b.String(), "  -timeout duration  how long to wait (default \"1s\")\n"))
+	})
+}
+
+func TestChoice(t *testing.T) {
+	t.Run("accepts one of the choices", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		format := middleware.Choice(fs, "format", "json", []string{"json", "yaml"}, "")
+
+		is.Equal(t, "json", *format)
+		is.NotError(t, fs.Parse([]string{"-format", "yaml"}))
+		is.Equal(t, "yaml", *format)
+	})
+
+	t.Run("errors with the choices on other values", func(t *testing.T) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		middleware.Choice(fs, "format", "json", []string{"json", "yaml"}, "")
+
+		err := fs.Parse([]string{"-format", "YAML"})
+		is.Equal(t, `invalid value "YAML" for flag -format: must be one of json, yaml`, err.Error())
+	})
+
+	t.Run("matches case-insensitively with ChoiceFold and sets the choice as given", func(t *testing.T) {
+		var a middleware.ArgSet
+		env := middleware.ChoiceFold(&a, "env", "", []string{"prod", "staging"}, "")
+
+		is.NotError(t, a.Parse([]string{"PROD"}))
+		is.Equal(t, "prod", *env)
+
+		is.NotError(t, a.Parse(nil))
+		is.Equal(t, "", *env)
+
+		err := a.Parse([]string{"dev"})
+		is.Equal(t, `invalid value "dev" for argument env: must be one of prod, staging`, err.Error())
+	})
+
+	t.Run("shows the choices in help", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(middleware.GNUFlags(func(fs *middleware.GNUFlagSet) {
+			middleware.Choice(fs, "format", "json", []string{"json", "yaml"}, "output format")
+		}))
+		r.Use(middleware.Args(func(as *middleware.ArgSet) {
+			middleware.Choice(as, "env", "", []string{"prod", "staging"}, "environment")
+		}))
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"--help"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.True(t, strings.Contains(b.String(), "  env  environment (one of prod, staging)\n"))
+		is.True(t, strings.Contains(b.String(), "  --format string  output format (one of json, yaml) (default \"json\")\n"))
+	})
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in  string
+		out middleware.ByteSize
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"1KB", 1000},
+		{"1KiB", 1024},
+		{"10MiB", 10 << 20},
+		{"10mib", 10 << 20},
+		{"1.5GB", 1500000000},
+		{"1.5 GiB", 3 << 29},
+		{"2TiB", 2 << 40},
+		{"1PB", 1e15},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			size, err := middleware.ParseByteSize(test.in)
+			is.NotError(t, err)
+			is.Equal(t, test.out, size)
+		})
+	}
+
+	t.Run("errors on invalid sizes", func(t *testing.T) {
+		for _, in := range []string{"", "MiB", "-1", "1XB", "20000PiB"} {
+			_, err := middleware.ParseByteSize(in)
+			is.True(t, err != nil)
+		}
+	})
+}
+
+func TestByteSize_String(t *testing.T) {
+	is.Equal(t, "0B", middleware.ByteSize(0).String())
+	is.Equal(t, "1500B", middleware.ByteSize(1500).String())
+	is.Equal(t, "10MiB", middleware.ByteSize(10<<20).String())
+	is.Equal(t, "2MB", middleware.ByteSize(2e6).String())
+	is.Equal(t, "1536KiB", middleware.ByteSize(1536<<10).String())
+}
+
+// slogLevel is a text-marshalling type for testing [middleware.TextVar].
+type slogLevel string
+
+func (l slogLevel) MarshalText() ([]byte, error) {
+	return []byte(l), nil
+}
+
+func (l *slogLevel) UnmarshalText(text []byte) error {
+	*l = slogLevel(strings.ToLower(string(text)))
+	return nil
+}
@@ -0,0 +1,120 @@
+package clir
+
+import (
+	"regexp"
+	"strings"
+)
+
+// routePattern of a route, with a regular expression for each arg it matches.
+type routePattern struct {
+	name     string   // name is the pattern as given, without anchors.
+	segments []string // segments of the name, one for each regular expression.
+	regexps  []*regexp.Regexp
+	wildcard bool // wildcard is true if the pattern ends with "*", so it matches any args after the segments.
+}
+
+// paramPattern matches named parameters in route patterns, like "{env}" or "{env:prod|staging}".
+var paramPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)(?::([^{}]*))?\}`)
+
+// parsePattern into segments separated by whitespace, expanding named parameters and anchoring each segment.
+func parsePattern(s string) routePattern {
+	fields := strings.Fields(s)
+
+	var p routePattern
+	if len(fields) > 0 && fields[len(fields)-1] == "*" {
+		p.wildcard = true
+		fields = fields[:len(fields)-1]
+	}
+	// The empty pattern is the root pattern, matching no args
+	if len(fields) == 0 && !p.wildcard {
+		fields = []string{""}
+	}
+
+	for _, field := range fields {
+		p.segments = append(p.segments, strings.TrimSuffix(strings.TrimPrefix(field, "^"), "$"))
+
+		// A "/*" tail matches the rest of the arg after the slash, captured as a submatch
+		if prefix, ok := strings.CutSuffix(field, "/*"); ok {
+			field = prefix + "/(.*)"
+		}
+
+		field = paramPattern.ReplaceAllStringFunc(field, func(param string) string {
+			groups := paramPattern.FindStringSubmatch(param)
+			if groups[2] == "" {
+				groups[2] = ".+"
+			}
+			return "(?P<" + groups[1] + ">" + groups[2] + ")"
+		})
+
+		if !strings.HasPrefix(field, "^") {
+			field = "^" + field
+		}
+		if !strings.HasSuffix(field, "$") {
+			field += "$"
+		}
+		p.regexps = append(p.regexps, regexp.MustCompile(field))
+	}
+
+	p.name = strings.Join(p.segments, " ")
+	if p.wildcard {
+		p.name = strings.TrimSpace(p.name + " *")
+	}
+
+	return p
+}
+
+// key of the pattern, which is the same for patterns matching the same args.
+func (p routePattern) key() string {
+	var regexps []string
+	for _, r := range p.regexps {
+		regexps = append(regexps, r.String())
+	}
+	if p.wildcard {
+		regexps = append(regexps, "*")
+	}
+	return strings.Join(regexps, " ")
+}
+
+// isRoot returns whether this is the root pattern, which matches no args.
+func (p routePattern) isRoot() bool {
+	return len(p.regexps) == 1 && p.regexps[0].String() == "^$" && !p.wildcard
+}
+
+// match the args and return how many of them the pattern consumes.
+func (p routePattern) match(args []string) (int, bool) {
+	if len(args) == 0 && p.isRoot() {
+		return 0, true
+	}
+
+	if len(args) < len(p.regexps) {
+		return 0, false
+	}
+	for i, r := range p.regexps {
+		if !r.MatchString(args[i]) {
+			return 0, false
+		}
+	}
+	return len(p.regexps), true
+}
+
+// submatches of the regular expressions for the matched args, with their subexpression names.
+func (p routePattern) submatches(args []string) (matches, names []string) {
+	for i, r := range p.regexps {
+		matches = append(matches, r.FindStringSubmatch(args[i])...)
+		names = append(names, r.SubexpNames()...)
+	}
+	return matches, names
+}
+
+// next segment of the pattern after the words, if the words match the segments before it.
+func (p routePattern) next(words []string) (string, bool) {
+	if len(words) >= len(p.segments) {
+		return "", false
+	}
+	for i, w := range words {
+		if !p.regexps[i].MatchString(w) {
+			return "", false
+		}
+	}
+	return p.segments[len(words)], true
+}
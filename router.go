@@ -2,26 +2,139 @@ package clir
 
 import (
 	"fmt"
-	"regexp"
+	"slices"
 	"strings"
 )
 
 // Router for [Runner]-s which itself satisfies [Runner].
 type Router struct {
-	middlewares []Middleware
-	patterns    []*regexp.Regexp
-	runners     map[string]Runner
+	completion     bool
+	help           HelpFunc
+	middlewares    []Middleware
+	notFoundRunner Runner
+	patterns       map[string]bool
+	routes         []*route
+}
+
+// route is either patterns with a [Runner] and [Meta], or a scope with its own [Router].
+// The first pattern is the one the route was added with, the rest are from [WithAliases].
+type route struct {
+	meta     Meta
+	patterns []routePattern
+	runner   Runner
+	scope    *Router
+}
+
+// Meta is human-facing information about a route, used for help output, docs generation, and completion.
+type Meta struct {
+	Summary     string    // Summary is a short, one-line description.
+	Description string    // Description is a longer description, shown in the help for the route itself.
+	Examples    []string  // Examples of usage.
+	Aliases     []string  // Aliases are additional patterns routing to the same runner.
+	Hidden      bool      // Hidden routes are routed, but not shown in help output.
+	Deprecated  string    // Deprecated is a message shown when the route is run, if not empty.
+	Completer   Completer // Completer for args matching a non-literal pattern, used for completion.
+}
+
+// RouteOption for [Router.Route], [Router.RouteFunc], and [Router.Branch].
+type RouteOption func(m *Meta)
+
+// WithSummary sets [Meta.Summary].
+func WithSummary(summary string) RouteOption {
+	return func(m *Meta) {
+		m.Summary = summary
+	}
+}
+
+// WithDescription sets [Meta.Description].
+func WithDescription(description string) RouteOption {
+	return func(m *Meta) {
+		m.Description = description
+	}
+}
+
+// WithExamples adds to [Meta.Examples].
+func WithExamples(examples ...string) RouteOption {
+	return func(m *Meta) {
+		m.Examples = append(m.Examples, examples...)
+	}
+}
+
+// WithAliases adds to [Meta.Aliases].
+// Aliases are patterns just like the route pattern.
+func WithAliases(aliases ...string) RouteOption {
+	return func(m *Meta) {
+		m.Aliases = append(m.Aliases, aliases...)
+	}
+}
+
+// WithHidden sets [Meta.Hidden].
+func WithHidden() RouteOption {
+	return func(m *Meta) {
+		m.Hidden = true
+	}
+}
+
+// WithDeprecated sets [Meta.Deprecated], which also hides the route in help output.
+func WithDeprecated(message string) RouteOption {
+	return func(m *Meta) {
+		m.Deprecated = message
+	}
+}
+
+// RouteInfo about a route, as returned by [Router.Routes].
+type RouteInfo struct {
+	Meta
+	Pattern string  // Pattern of the route, without the anchors added by [Router.Route].
+	Router  *Router // Router is set if the route is a [Router], like from [Router.Branch].
+}
+
+// Routes of the router in the order they were added, including routes in scopes.
+func (r *Router) Routes() []RouteInfo {
+	var routes []RouteInfo
+	for _, route := range r.routes {
+		if route.scope != nil {
+			routes = append(routes, route.scope.Routes()...)
+			continue
+		}
+
+		router, _ := route.runner.(*Router)
+		routes = append(routes, RouteInfo{Meta: route.meta, Pattern: route.name(), Router: router})
+	}
+	return routes
 }
 
 func NewRouter() *Router {
 	return &Router{
-		runners: map[string]Runner{},
+		patterns: map[string]bool{},
 	}
 }
 
 // Run satisfies [Runner].
 func (r *Router) Run(ctx Context) error {
-	// Apply middlewares first, because they can modify the context, including the Context.Args to match against.
+	if r.completion && len(ctx.Args) > 0 && ctx.Args[0] == "__complete" {
+		return r.complete(ctx)
+	}
+
+	if args, ok := r.helpRequested(ctx.Args); ok {
+		return r.renderHelp(ctx, args)
+	}
+
+	ctx, called, err := r.applyMiddlewares(ctx)
+	if err != nil {
+		return err
+	}
+	if !called {
+		return nil
+	}
+
+	return r.route(ctx)
+}
+
+// applyMiddlewares of the router to the context, and return the context passed on by the innermost middleware,
+// and whether it was called at all.
+// Middlewares are applied first, because they can modify the context, including the Context.Args to match against.
+func (r *Router) applyMiddlewares(ctx Context) (Context, bool, error) {
 	var called bool
 	var middlewareCtx Context
 	var runner Runner = RunnerFunc(func(ctx Context) error {
@@ -34,72 +147,153 @@ func (r *Router) Run(ctx Context) error {
 		runner = r.middlewares[i](runner)
 	}
 	if err := runner.Run(ctx); err != nil {
-		return fmt.Errorf("error while applying middleware: %w", err)
+		return ctx, false, fmt.Errorf("error while applying middleware: %w", err)
 	}
-	if !called {
-		return nil
+	return middlewareCtx, called, nil
+}
+
+// route the context to the first matching route, after the middlewares of the router have been applied.
+func (r *Router) route(ctx Context) error {
+	if r.help != nil {
+		ctx.help = r.help
 	}
-	ctx = middlewareCtx
 
-	for _, pattern := range r.patterns {
-		if (len(ctx.Args) == 0 && pattern.String() == "^$") || (len(ctx.Args) > 0 && pattern.MatchString(ctx.Args[0])) {
-			runner = r.runners[pattern.String()]
-			if len(ctx.Args) > 0 {
-				ctx.Matches = pattern.FindStringSubmatch(ctx.Args[0])
-				ctx.Args = ctx.Args[1:]
+	for _, route := range r.routes {
+		// Scopes are only entered if one of their routes match, so that their middlewares are not applied otherwise.
+		if route.scope != nil {
+			if route.scope.matches(ctx.Args) {
+				return route.scope.Run(ctx)
+			}
+			continue
+		}
+
+		if pattern, n, ok := route.match(ctx.Args); ok {
+			if n > 0 {
+				var names []string
+				ctx.Matches, names = pattern.submatches(ctx.Args[:n])
+				ctx.pathValues = withPathValues(ctx.pathValues, names, ctx.Matches)
+				ctx.path = append(slices.Clone(ctx.path), ctx.Args[:n]...)
+				ctx.Args = ctx.Args[n:]
+			}
+
+			if route.meta.Deprecated != "" {
+				ctx.Errorfln("Command %q is deprecated: %v", strings.Join(ctx.path, " "), route.meta.Deprecated)
 			}
 
-			return runner.Run(ctx)
+			return route.runner.Run(ctx)
 		}
 	}
 
-	//for _, router := range r.routers {
-	//	if err := router.Run(ctx); err == nil {
-	//		return err
-	//	}
-	//}
+	// Middlewares of scopes can parse flags before the route name, so if no route matches the args as they are,
+	// try each scope again with the leading flags described by its middlewares skipped, without running them.
+	for _, route := range r.routes {
+		if route.scope != nil && route.scope.matches(skipFlags(ctx.Args, route.scope.middlewareInputs().Flags)) {
+			return route.scope.Run(ctx)
+		}
+	}
+
+	if r.notFoundRunner != nil {
+		return r.notFoundRunner.Run(ctx)
+	}
+
+	return r.notFound(ctx)
+}
 
-	return ErrorRouteNotFound
+// matches returns whether any route in the router, including routes in scopes, matches the args.
+func (r *Router) matches(args []string) bool {
+	route, _, _ := r.find(args)
+	return route != nil
+}
+
+// match returns the first route pattern matching the args, and how many args it consumes.
+func (r *route) match(args []string) (routePattern, int, bool) {
+	for _, p := range r.patterns {
+		if n, ok := p.match(args); ok {
+			return p, n, true
+		}
+	}
+	return routePattern{}, 0, false
+}
+
+// name of the route, which is its pattern as given, without anchors.
+func (r *route) name() string {
+	return r.patterns[0].name
 }
 
 // Route a [Runner] with the given pattern.
 // Routes are matched in the order they were added.
-func (r *Router) Route(pattern string, runner Runner) {
-	if !strings.HasPrefix(pattern, "^") {
-		pattern = "^" + pattern
-	}
-	if !strings.HasSuffix(pattern, "$") {
-		pattern += "$"
+//
+// The pattern is a regular expression, which must match the whole arg.
+// Patterns with several segments separated by whitespace, like "db migrate up", match that many args,
+// one segment each, and all matched args are consumed before running the runner.
+// Args not consumed by the pattern are left in [Context.Args] for the runner.
+// The pattern "*" is a catch-all, matching any args including none, and consuming none of them.
+// A segment ending in "/*", like "files/*", matches any arg starting with the part before the "*", like "files/a/b",
+// with the rest of the arg in [Context.Matches]. A "*" segment of its own at the end, like "files *", matches any args after.
+//
+// Named parameters like "{env}" match anything, and "{env:prod|staging}" match the given regular expression.
+// They are short for the named groups "(?P<env>.+)" and "(?P<env>prod|staging)", which can be used as well.
+// Get their values with [Context.PathValue].
+func (r *Router) Route(pattern string, runner Runner, opts ...RouteOption) {
+	var m Meta
+	for _, opt := range opts {
+		opt(&m)
 	}
 
-	if _, ok := r.runners[pattern]; ok {
-		panic("cannot add route which already exists")
+	var patterns []routePattern
+	for _, s := range append([]string{pattern}, m.Aliases...) {
+		p := parsePattern(s)
+
+		if r.patterns[p.key()] {
+			panic("cannot add route which already exists")
+		}
+		r.patterns[p.key()] = true
+
+		patterns = append(patterns, p)
 	}
 
-	r.patterns = append(r.patterns, regexp.MustCompile(pattern))
-	r.runners[pattern] = runner
+	r.routes = append(r.routes, &route{meta: m, patterns: patterns, runner: runner})
 }
 
 // RouteFunc is like [Router.Route], but with a [RunnerFunc].
-func (r *Router) RouteFunc(pattern string, runner RunnerFunc) {
-	r.Route(pattern, runner)
+func (r *Router) RouteFunc(pattern string, runner RunnerFunc, opts ...RouteOption) {
+	r.Route(pattern, runner, opts...)
 }
 
 // Branch into a new [Router] with the given pattern.
-func (r *Router) Branch(pattern string, cb func(r *Router)) {
+func (r *Router) Branch(pattern string, cb func(r *Router), opts ...RouteOption) {
 	newR := NewRouter()
 	cb(newR)
-	r.Route(pattern, newR)
+	r.Route(pattern, newR, opts...)
 }
 
 // Scope into a new [Router].
 // The middlewares from the parent router are used in the new router,
 // but new middlewares within the scope are only added to the new router, not the parent router.
+// Routes in the scope are matched in the order of the parent router, and share its patterns,
+// so a route in a scope cannot have the same pattern as a route in the parent.
+//
+// A scope is entered if one of its routes matches the args, also after skipping leading flags described by its middlewares,
+// like with [Describer], so flags in a scope can be given before the route name.
+// The middlewares of scopes which are not entered are never run.
 func (r *Router) Scope(cb func(r *Router)) {
-	panic("not implemented")
-	//newR := NewRouter()
-	//cb(newR)
-	//r.routers = append(r.routers, newR)
+	newR := &Router{
+		patterns: r.patterns,
+	}
+	cb(newR)
+	r.routes = append(r.routes, &route{scope: newR})
+}
+
+// NotFound sets a [Runner] to run instead of returning a [NotFoundError] when no route matches.
+// It runs with the [Context] after the middlewares of the router have been applied, so the [Context.Args] are the unmatched args.
+// Scopes are only entered when one of their routes match, so NotFound is not used in a [Router.Scope].
+func (r *Router) NotFound(runner Runner) {
+	r.notFoundRunner = runner
+}
+
+// NotFoundFunc is like [Router.NotFound], but with a [RunnerFunc].
+func (r *Router) NotFoundFunc(runner RunnerFunc) {
+	r.NotFound(runner)
 }
 
 // Middleware for [Router.Use].
@@ -108,7 +302,7 @@ type Middleware = func(next Runner) Runner
 // Use [Middleware] on the current branch of the [Router].
 // If called in a [Scope], it will apply to all routes in that scope.
 func (r *Router) Use(middlewares ...Middleware) {
-	if len(r.runners) > 0 {
+	if len(r.routes) > 0 {
 		panic("cannot add middlewares after adding routes")
 	}
 	r.middlewares = append(r.middlewares, middlewares...)
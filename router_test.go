@@ -1,6 +1,7 @@
 package clir_test
 
 import (
+	"errors"
 	"flag"
 	"strings"
 	"testing"
@@ -8,6 +9,7 @@ import (
 	"maragu.dev/is"
 
 	"maragu.dev/clir"
+	"maragu.dev/clir/middleware"
 )
 
 func TestRouter_Run(t *testing.T) {
@@ -15,7 +17,7 @@ func TestRouter_Run(t *testing.T) {
 		r := clir.NewRouter()
 
 		err := r.Run(clir.Context{})
-		is.Error(t, err, clir.ErrorRouteNotFound)
+		is.Error(t, clir.ErrorRouteNotFound, err)
 	})
 
 	t.Run("errors on run if there is no named route", func(t *testing.T) {
@@ -24,7 +26,63 @@ func TestRouter_Run(t *testing.T) {
 		err := r.Run(clir.Context{
 			Args: []string{"dance"},
 		})
-		is.Error(t, err, clir.ErrorRouteNotFound)
+		is.Error(t, clir.ErrorRouteNotFound, err)
+	})
+
+	t.Run("errors with suggestions for similar routes", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		for _, pattern := range []string{"status", "stats", "start", "secret", `\d+`} {
+			r.RouteFunc(pattern, func(ctx clir.Context) error {
+				return nil
+			}, func(m *clir.Meta) {
+				m.Hidden = pattern == "secret"
+			})
+		}
+
+		err := r.Run(clir.Context{
+			Args: []string{"stauts"},
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+
+		var notFoundErr *clir.NotFoundError
+		is.True(t, errors.As(err, &notFoundErr))
+		is.Equal(t, "stauts", notFoundErr.Arg)
+		is.Equal(t, 0, len(notFoundErr.Path))
+		is.Equal(t, "stats,status,start", strings.Join(notFoundErr.Suggestions, ","))
+		is.Equal(t, "unknown command 'stauts', did you mean 'stats' or 'status' or 'start'?", err.Error())
+	})
+
+	t.Run("errors with the path to the branch where matching failed", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Branch("db", func(r *clir.Router) {
+			r.RouteFunc("migrate up", func(ctx clir.Context) error {
+				return nil
+			})
+
+			r.RouteFunc("migrate down", func(ctx clir.Context) error {
+				return nil
+			})
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"db", "migrate", "dwn"},
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+		is.Equal(t, "unknown command 'dwn' for 'db migrate', did you mean 'down'?", err.Error())
+
+		err = r.Run(clir.Context{
+			Args: []string{"db", "migrate"},
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+		is.Equal(t, "route not found for 'db migrate'", err.Error())
+
+		err = r.Run(clir.Context{
+			Args: []string{"db", "xyz"},
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+		is.Equal(t, "unknown command 'xyz' for 'db'", err.Error())
 	})
 }
 
@@ -114,6 +172,408 @@ func TestRouter_RouteFunc(t *testing.T) {
 		is.NotError(t, err)
 		is.True(t, called)
 	})
+
+	t.Run("supports named parameters in routes", func(t *testing.T) {
+		tests := []struct {
+			pattern string
+			arg     string
+		}{
+			{"{env}", "staging"},
+			{"{env:prod|staging}", "staging"},
+			{"(?P<env>prod|staging)", "staging"},
+			{"env-{env}", "env-staging"},
+		}
+
+		for _, test := range tests {
+			t.Run(test.pattern, func(t *testing.T) {
+				r := clir.NewRouter()
+
+				var called bool
+				r.RouteFunc(test.pattern, func(ctx clir.Context) error {
+					called = true
+					is.Equal(t, "staging", ctx.PathValue("env"))
+					is.Equal(t, "", ctx.PathValue("nope"))
+					is.Equal(t, 2, len(ctx.Matches))
+					is.Equal(t, "staging", ctx.Matches[1])
+					return nil
+				})
+
+				err := r.Run(clir.Context{
+					Args: []string{test.arg},
+				})
+				is.NotError(t, err)
+				is.True(t, called)
+			})
+		}
+	})
+
+	t.Run("does not match named parameters with a non-matching regular expression", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("{env:prod|staging}", func(ctx clir.Context) error {
+			return nil
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"dev"},
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+	})
+
+	t.Run("has named parameters from branches in routes", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		var called bool
+		r.Branch("{env}", func(r *clir.Router) {
+			r.RouteFunc("deploy-{app}", func(ctx clir.Context) error {
+				called = true
+				is.Equal(t, "staging", ctx.PathValue("env"))
+				is.Equal(t, "api", ctx.PathValue("app"))
+				return nil
+			})
+		})
+
+		err := r.Run(clir.Context{
+			Args: []string{"staging", "deploy-api"},
+		})
+		is.NotError(t, err)
+		is.True(t, called)
+	})
+}
+
+func TestRouter_Route(t *testing.T) {
+	t.Run("can route patterns with several segments, consuming the matched args", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("db", func(ctx clir.Context) error {
+			ctx.Println("db", ctx.Args)
+			return nil
+		})
+
+		r.RouteFunc("db migrate up", func(ctx clir.Context) error {
+			ctx.Println("db migrate up", ctx.Args)
+			return nil
+		})
+
+		r.RouteFunc(`db migrate (?P<version>\d+)`, func(ctx clir.Context) error {
+			ctx.Println("db migrate", ctx.PathValue("version"), ctx.Matches, ctx.Args)
+			return nil
+		})
+
+		tests := []struct {
+			args     []string
+			expected string
+		}{
+			{[]string{"db", "migrate", "up"}, "db [migrate up]\n"},
+			{[]string{"db", "status"}, "db [status]\n"},
+		}
+
+		for _, test := range tests {
+			var b strings.Builder
+			err := r.Run(clir.Context{
+				Args: test.args,
+				Out:  &b,
+			})
+			is.NotError(t, err)
+			is.Equal(t, test.expected, b.String())
+		}
+	})
+
+	t.Run("matches patterns with more segments first if added first", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("db migrate up", func(ctx clir.Context) error {
+			ctx.Println("db migrate up", ctx.Args)
+			return nil
+		})
+
+		r.RouteFunc(`db migrate (?P<version>\d+)`, func(ctx clir.Context) error {
+			ctx.Println("db migrate", ctx.PathValue("version"), ctx.Matches, ctx.Args)
+			return nil
+		})
+
+		r.RouteFunc("db", func(ctx clir.Context) error {
+			ctx.Println("db", ctx.Args)
+			return nil
+		})
+
+		tests := []struct {
+			args     []string
+			expected string
+		}{
+			{[]string{"db", "migrate", "up", "-v"}, "db migrate up [-v]\n"},
+			{[]string{"db", "migrate", "42"}, "db migrate 42 [db migrate 42 42] []\n"},
+			{[]string{"db", "migrate", "down"}, "db [migrate down]\n"},
+			{[]string{"db", "migrate"}, "db [migrate]\n"},
+		}
+
+		for _, test := range tests {
+			var b strings.Builder
+			err := r.Run(clir.Context{
+				Args: test.args,
+				Out:  &b,
+			})
+			is.NotError(t, err)
+			is.Equal(t, test.expected, b.String())
+		}
+	})
+
+	t.Run("can route a catch-all pattern", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("dance", func(ctx clir.Context) error {
+			ctx.Println("dance", ctx.Args)
+			return nil
+		})
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			ctx.Println("catch-all", ctx.Args)
+			return nil
+		})
+
+		tests := []struct {
+			args     []string
+			expected string
+		}{
+			{[]string{"dance"}, "dance []\n"},
+			{[]string{"sleep", "now"}, "catch-all [sleep now]\n"},
+			{nil, "catch-all []\n"},
+		}
+
+		for _, test := range tests {
+			var b strings.Builder
+			err := r.Run(clir.Context{
+				Args: test.args,
+				Out:  &b,
+			})
+			is.NotError(t, err)
+			is.Equal(t, test.expected, b.String())
+		}
+	})
+
+	t.Run("can route a pattern with a slash wildcard tail", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("files/*", func(ctx clir.Context) error {
+			ctx.Println("files", ctx.Matches, ctx.Args)
+			return nil
+		})
+
+		r.RouteFunc("*", func(ctx clir.Context) error {
+			ctx.Println("catch-all", ctx.Args)
+			return nil
+		})
+
+		tests := []struct {
+			args     []string
+			expected string
+		}{
+			{[]string{"files/a/b"}, "files [files/a/b a/b] []\n"},
+			{[]string{"files/", "now"}, "files [files/ ] [now]\n"},
+			{[]string{"files"}, "catch-all [files]\n"},
+			{[]string{"files//"}, "files [files// /] []\n"},
+		}
+
+		for _, test := range tests {
+			var b strings.Builder
+			err := r.Run(clir.Context{
+				Args: test.args,
+				Out:  &b,
+			})
+			is.NotError(t, err)
+			is.Equal(t, test.expected, b.String())
+		}
+	})
+
+	t.Run("panics if a pattern with several segments already exists", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("db migrate", func(ctx clir.Context) error {
+			return nil
+		})
+
+		defer func() {
+			if rec := recover(); rec == nil {
+				t.FailNow()
+			}
+		}()
+
+		r.RouteFunc("db  migrate", func(ctx clir.Context) error {
+			return nil
+		})
+	})
+
+	t.Run("can route aliases to the same runner", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		var calls int
+		r.RouteFunc("status", func(ctx clir.Context) error {
+			calls++
+			return nil
+		}, clir.WithAliases("st", "stat"))
+
+		for _, arg := range []string{"status", "st", "stat"} {
+			err := r.Run(clir.Context{
+				Args: []string{arg},
+			})
+			is.NotError(t, err)
+		}
+		is.Equal(t, 3, calls)
+	})
+
+	t.Run("panics if an alias already exists", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("st", func(ctx clir.Context) error {
+			return nil
+		})
+
+		defer func() {
+			if rec := recover(); rec == nil {
+				t.FailNow()
+			}
+		}()
+
+		r.RouteFunc("status", func(ctx clir.Context) error {
+			return nil
+		}, clir.WithAliases("st"))
+	})
+
+	t.Run("prints a warning when running a deprecated route", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.RouteFunc("waltz", func(ctx clir.Context) error {
+				ctx.Println("waltz")
+				return nil
+			}, clir.WithDeprecated("use salsa instead"))
+		})
+
+		var out, errOut strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance", "waltz"},
+			Err:  &errOut,
+			Out:  &out,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "waltz\n", out.String())
+		is.Equal(t, "Command \"dance waltz\" is deprecated: use salsa instead\n", errOut.String())
+	})
+}
+
+func TestRouter_Routes(t *testing.T) {
+	t.Run("returns routes with metadata in order, including scopes", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		}, clir.WithSummary("Root."))
+
+		r.Scope(func(r *clir.Router) {
+			r.RouteFunc("secret", func(ctx clir.Context) error {
+				return nil
+			}, clir.WithHidden())
+		})
+
+		r.Branch("dance", func(r *clir.Router) {}, clir.WithSummary("Dance."), clir.WithDescription("Dance all night."),
+			clir.WithExamples("dance salsa", "dance tango"), clir.WithAliases("d"))
+
+		routes := r.Routes()
+		is.Equal(t, 3, len(routes))
+
+		is.Equal(t, "", routes[0].Pattern)
+		is.Equal(t, "Root.", routes[0].Summary)
+		is.True(t, routes[0].Router == nil)
+
+		is.Equal(t, "secret", routes[1].Pattern)
+		is.True(t, routes[1].Hidden)
+
+		is.Equal(t, "dance", routes[2].Pattern)
+		is.Equal(t, "Dance.", routes[2].Summary)
+		is.Equal(t, "Dance all night.", routes[2].Description)
+		is.Equal(t, 2, len(routes[2].Examples))
+		is.Equal(t, "d", routes[2].Aliases[0])
+		is.True(t, routes[2].Router != nil)
+	})
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	t.Run("runs the not found runner with the unmatched context after middlewares", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(newMiddleware(t, "m1"))
+
+		r.RouteFunc("dance", func(ctx clir.Context) error {
+			ctx.Println("dance")
+			return nil
+		})
+
+		r.NotFoundFunc(func(ctx clir.Context) error {
+			ctx.Println("not found", ctx.Args)
+			return nil
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"sleep", "now"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "m1\nnot found [sleep now]\n", b.String())
+	})
+
+	t.Run("runs the not found runner of the branch", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.NotFoundFunc(func(ctx clir.Context) error {
+			ctx.Println("root not found")
+			return nil
+		})
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.Use(newMiddleware(t, "m1"))
+
+			r.RouteFunc("salsa", func(ctx clir.Context) error {
+				return nil
+			})
+
+			r.NotFoundFunc(func(ctx clir.Context) error {
+				ctx.Println("dance not found", ctx.Args)
+				return errors.New("oh no")
+			})
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance", "tango"},
+			Out:  &b,
+		})
+		is.Equal(t, "oh no", err.Error())
+		is.Equal(t, "m1\ndance not found [tango]\n", b.String())
+	})
+
+	t.Run("errors in branches without a not found runner", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.NotFoundFunc(func(ctx clir.Context) error {
+			ctx.Println("root not found")
+			return nil
+		})
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.RouteFunc("salsa", func(ctx clir.Context) error {
+				return nil
+			})
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance", "tango"},
+			Out:  &b,
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+		is.Equal(t, "", b.String())
+	})
 }
 
 func TestRouter_Use(t *testing.T) {
@@ -220,10 +680,7 @@ func TestRouter_Use(t *testing.T) {
 	})
 }
 
-//nolint:staticcheck
 func TestRouter_Scope(t *testing.T) {
-	t.Skip("not implemented")
-
 	t.Run("can scope routes with a new middleware stack", func(t *testing.T) {
 		r := clir.NewRouter()
 
@@ -340,6 +797,150 @@ func TestRouter_Scope(t *testing.T) {
 		is.NotError(t, err)
 		is.Equal(t, "m1\nm2\nm3\nsleep\n", b.String())
 	})
+
+	t.Run("errors on run if no route in a scope matches", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Scope(func(r *clir.Router) {
+			r.Use(newMiddleware(t, "m1"))
+
+			r.RouteFunc("dance", func(ctx clir.Context) error {
+				return nil
+			})
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"sleep"},
+			Out:  &b,
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+		is.Equal(t, "", b.String())
+	})
+
+	t.Run("matches routes in a scope after flags described by its middlewares", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		m1 := newMiddleware(t, "m1")
+
+		r.RouteFunc("sleep", func(ctx clir.Context) error {
+			return nil
+		})
+
+		r.Scope(func(r *clir.Router) {
+			r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+				fs.Bool("v", false, "")
+			}))
+			r.Use(m1)
+
+			r.RouteFunc("dance", func(ctx clir.Context) error {
+				v, _ := middleware.Flag[bool](ctx, "v")
+				ctx.Println("dance", v)
+				return nil
+			})
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-v", "dance"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "m1\ndance true\n", b.String())
+
+		b.Reset()
+		err = r.Run(clir.Context{
+			Args: []string{"-x", "dance"},
+			Out:  &b,
+		})
+		is.Error(t, clir.ErrorRouteNotFound, err)
+		is.Equal(t, "", b.String())
+	})
+
+	t.Run("does not run middlewares of other scopes when matching flags before the route name", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Scope(func(r *clir.Router) {
+			r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+				fs.Bool("a", false, "")
+			}))
+
+			r.RouteFunc("one", func(ctx clir.Context) error {
+				return nil
+			})
+		})
+
+		r.Scope(func(r *clir.Router) {
+			r.Use(middleware.Flags(func(fs *flag.FlagSet) {
+				fs.Bool("b", false, "")
+			}))
+
+			r.RouteFunc("two", func(ctx clir.Context) error {
+				b, _ := middleware.Flag[bool](ctx, "b")
+				ctx.Println("two", b)
+				return nil
+			})
+		})
+
+		var out, errOut strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"-b", "two"},
+			Out:  &out,
+			Err:  &errOut,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "two true\n", out.String())
+		is.Equal(t, "", errOut.String())
+	})
+
+	t.Run("returns not found with suggestions instead of scope middleware errors", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("status", func(ctx clir.Context) error {
+			return nil
+		})
+
+		var authCalled bool
+		r.Scope(func(r *clir.Router) {
+			r.Use(func(next clir.Runner) clir.Runner {
+				return clir.RunnerFunc(func(ctx clir.Context) error {
+					authCalled = true
+					return errors.New("not logged in")
+				})
+			})
+
+			r.RouteFunc("deploy", func(ctx clir.Context) error {
+				return nil
+			})
+		})
+
+		err := r.Run(clir.Context{Args: []string{"stauts"}})
+		var notFoundErr *clir.NotFoundError
+		is.True(t, errors.As(err, &notFoundErr))
+		is.Equal(t, "unknown command 'stauts', did you mean 'status'?", err.Error())
+		is.Equal(t, 2, clir.ExitCode(err))
+		is.True(t, !authCalled)
+	})
+
+	t.Run("panics if the route already exists in the parent", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("dance", func(ctx clir.Context) error {
+			return nil
+		})
+
+		defer func() {
+			if rec := recover(); rec == nil {
+				t.FailNow()
+			}
+		}()
+
+		r.Scope(func(r *clir.Router) {
+			r.RouteFunc("dance", func(ctx clir.Context) error {
+				return nil
+			})
+		})
+	})
 }
 
 func TestRouter_Branch(t *testing.T) {
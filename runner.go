@@ -3,10 +3,13 @@ package clir
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 )
 
@@ -14,10 +17,106 @@ import (
 type Context struct {
 	Args    []string
 	Ctx     context.Context
+	Dir     string                          // Dir is the working directory, see [Context.Abs].
+	Env     func(key string) (string, bool) // Env looks up environment variables, see [Context.LookupEnv].
 	Err     io.Writer
 	In      io.Reader
 	Matches []string
+	Name    string // Name of the program, used in help output and completion scripts. Defaults to the base name of os.Args[0] if empty.
 	Out     io.Writer
+
+	help       HelpFunc          // help is the [HelpFunc] set on the closest [Router] routed through, if any.
+	path       []string          // path of args routed through so far, for help output.
+	pathValues map[string]string // pathValues from named parameters in route patterns, see [Context.PathValue].
+}
+
+// programName is [Context.Name], or the base name of os.Args[0] if it's empty.
+func (c Context) programName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return filepath.Base(os.Args[0])
+}
+
+// PathValue returns the value of the named parameter in the route patterns matched so far,
+// like "env" in the pattern "{env}" or "(?P<env>prod|staging)", similar to [net/http.Request.PathValue].
+// Parameters in branches are available in their routes, and later parameters override earlier ones with the same name.
+// It returns the empty string if there is no such parameter.
+func (c Context) PathValue(name string) string {
+	return c.pathValues[name]
+}
+
+// withPathValues returns a copy of the path values with the named submatches added.
+func withPathValues(pathValues map[string]string, names, matches []string) map[string]string {
+	newPathValues := maps.Clone(pathValues)
+	if newPathValues == nil {
+		newPathValues = map[string]string{}
+	}
+	for i, name := range names {
+		if name != "" && i < len(matches) {
+			newPathValues[name] = matches[i]
+		}
+	}
+	return newPathValues
+}
+
+// valueKey for values of type T in [Context.Ctx], see [WithValue].
+type valueKey[T any] struct{}
+
+// WithValue returns a copy of the [Context] with the value added to [Context.Ctx], to get with [Value].
+// Values are keyed by their type, so a value replaces an earlier value of the same type,
+// and packages should use their own types to not collide with others.
+// If [Context.Ctx] is nil, [context.Background] is used as the parent.
+//
+// Because the [Context] is passed by value, the value is only seen by runners called with the returned [Context],
+// like the next runner in a [Middleware] and the routes of a [Router].
+func WithValue[T any](ctx Context, v T) Context {
+	parent := ctx.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx.Ctx = context.WithValue(parent, valueKey[T]{}, v)
+	return ctx
+}
+
+// Value returns the value of type T added with [WithValue], and whether it was found.
+func Value[T any](ctx Context) (T, bool) {
+	if ctx.Ctx == nil {
+		var zero T
+		return zero, false
+	}
+	v, ok := ctx.Ctx.Value(valueKey[T]{}).(T)
+	return v, ok
+}
+
+// LookupEnv looks up the environment variable with the given key using [Context.Env],
+// like [os.LookupEnv]. If [Context.Env] is nil, it uses [os.LookupEnv].
+func (c Context) LookupEnv(key string) (string, bool) {
+	if c.Env == nil {
+		return os.LookupEnv(key)
+	}
+	return c.Env(key)
+}
+
+// Getenv is like [Context.LookupEnv], but returns the empty string if the environment variable is not set, like [os.Getenv].
+func (c Context) Getenv(key string) string {
+	v, _ := c.LookupEnv(key)
+	return v
+}
+
+// Abs returns the path joined with [Context.Dir] if it's relative.
+// If [Context.Dir] is empty, it's like [filepath.Abs], using the working directory of the process.
+func (c Context) Abs(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if c.Dir == "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+		return path
+	}
+	return filepath.Join(c.Dir, path)
 }
 
 func (c Context) Println(a ...any) {
@@ -52,25 +151,131 @@ func (f RunnerFunc) Run(ctx Context) error {
 // Run a [Runner] with a default [Context], which is:
 // - Get args from [os.Args]
 // - Create context which is cancelled on [syscall.SIGTERM] or [syscall.SIGINT]
+// - Look up environment variables with [os.LookupEnv]
+// - Use the working directory from [os.Getwd]
 // - Use [os.Stdin] for input
 // - Use [os.Stdout] for output
 // - Use [os.Stderr] for errors
-// - Prints to [os.Stderr] and calls os.Exit(1) on errors from [Runner.Run]
+// - Prints to [os.Stderr] and calls [os.Exit] on errors from [Runner.Run], with the code from [ExitCode]
+//
+// If the context is cancelled by a signal and the error from [Runner.Run] is [context.Canceled],
+// the exit code is 128 plus the signal number, like 130 for [syscall.SIGINT].
+//
+// Run is a thin wrapper around [RunWithOptions], which can change all of the above.
 func Run(r Runner) {
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	RunWithOptions(r, RunOptions{Exit: os.Exit})
+}
+
+// RunOptions for [RunWithOptions]. The zero value of each option uses the default from [Run], except for Exit.
+type RunOptions struct {
+	Args       []string                        // Args without the program name. Defaults to [os.Args] without the program name if nil.
+	Ctx        context.Context                 // Ctx is the parent of the [Context.Ctx]. Defaults to [context.Background].
+	Dir        string                          // Dir for [Context.Dir]. Defaults to [os.Getwd].
+	Env        func(key string) (string, bool) // Env for [Context.Env]. Defaults to [os.LookupEnv].
+	Err        io.Writer                       // Err defaults to [os.Stderr].
+	Exit       func(code int)                  // Exit is called with non-zero exit codes, if not nil.
+	In         io.Reader                       // In defaults to [os.Stdin].
+	Name       string                          // Name for [Context.Name]. Defaults to the base name of os.Args[0].
+	Out        io.Writer                       // Out defaults to [os.Stdout].
+	PrintError func(ctx Context, err error)    // PrintError prints errors. Defaults to printing "Error:" and the error to [Context.Err].
+	Signals    []os.Signal                     // Signals cancelling the context, none if empty. Defaults to [syscall.SIGTERM] and [syscall.SIGINT] if nil.
+}
+
+// RunWithOptions runs a [Runner] like [Run], but with [RunOptions], and returns the exit code instead of exiting.
+func RunWithOptions(r Runner, opts RunOptions) int {
+	if opts.Args == nil {
+		opts.Args = os.Args[1:]
+	}
+	if opts.Ctx == nil {
+		opts.Ctx = context.Background()
+	}
+	if opts.Dir == "" {
+		opts.Dir, _ = os.Getwd()
+	}
+	if opts.Env == nil {
+		opts.Env = os.LookupEnv
+	}
+	if opts.Err == nil {
+		opts.Err = os.Stderr
+	}
+	if opts.In == nil {
+		opts.In = os.Stdin
+	}
+	if opts.Name == "" {
+		opts.Name = filepath.Base(os.Args[0])
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	if opts.PrintError == nil {
+		opts.PrintError = func(ctx Context, err error) {
+			ctx.Errorln("Error:", err)
+		}
+	}
+	if opts.Signals == nil {
+		opts.Signals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+
+	ctx, stop := notifyContext(opts.Ctx, opts.Signals...)
 	defer stop()
 
 	runCtx := Context{
-		Args: os.Args[1:],
+		Args: opts.Args,
 		Ctx:  ctx,
-		Err:  os.Stderr,
-		In:   os.Stdin,
-		Out:  os.Stdout,
+		Dir:  opts.Dir,
+		Env:  opts.Env,
+		Err:  opts.Err,
+		In:   opts.In,
+		Name: opts.Name,
+		Out:  opts.Out,
+	}
+
+	err := r.Run(runCtx)
+	if err == nil {
+		return 0
+	}
+
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		if cause := context.Cause(ctx); cause != nil {
+			err = Exit(ExitCode(cause), err)
+		}
+	}
+	opts.PrintError(runCtx, err)
+
+	code := ExitCode(err)
+	if opts.Exit != nil {
+		opts.Exit(code)
 	}
+	return code
+}
+
+// notifyContext is like [signal.NotifyContext], but the context cause is an [ExitError]
+// with 128 plus the signal number as the exit code.
+func notifyContext(parent context.Context, signals ...os.Signal) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	if len(signals) == 0 {
+		return ctx, func() { cancel(nil) }
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+
+	go func() {
+		select {
+		case sig := <-c:
+			code := 1
+			if s, ok := sig.(syscall.Signal); ok {
+				code = 128 + int(s)
+			}
+			cancel(Exit(code, fmt.Errorf("received signal %v", sig)))
+		case <-ctx.Done():
+		}
+	}()
 
-	if err := r.Run(runCtx); err != nil {
-		runCtx.Errorln("Error:", err)
-		os.Exit(1)
+	return ctx, func() {
+		signal.Stop(c)
+		cancel(nil)
 	}
 }
 
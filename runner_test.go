@@ -1,6 +1,14 @@
 package clir_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"maragu.dev/is"
@@ -18,3 +26,312 @@ func TestRun(t *testing.T) {
 		is.True(t, called)
 	})
 }
+
+func TestRunWithOptions(t *testing.T) {
+	t.Run("runs with the given args and streams", func(t *testing.T) {
+		var out, errOut strings.Builder
+		code := clir.RunWithOptions(clir.RunnerFunc(func(ctx clir.Context) error {
+			in, err := io.ReadAll(ctx.In)
+			if err != nil {
+				return err
+			}
+			ctx.Println(ctx.Args, string(in))
+			ctx.Errorln("to err")
+			return nil
+		}), clir.RunOptions{
+			Args: []string{"dance"},
+			Err:  &errOut,
+			In:   strings.NewReader("salsa"),
+			Out:  &out,
+		})
+		is.Equal(t, 0, code)
+		is.Equal(t, "[dance] salsa\n", out.String())
+		is.Equal(t, "to err\n", errOut.String())
+	})
+
+	t.Run("uses the name in help and completion scripts", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.RouteFunc("", func(ctx clir.Context) error {
+			return nil
+		})
+
+		r.Completion()
+
+		var out strings.Builder
+		code := clir.RunWithOptions(r, clir.RunOptions{
+			Args: []string{"--help"},
+			Name: "app",
+			Out:  &out,
+		})
+		is.Equal(t, 0, code)
+		is.True(t, strings.HasPrefix(out.String(), "Usage:\n  app [command]\n"))
+
+		out.Reset()
+		code = clir.RunWithOptions(r, clir.RunOptions{
+			Args: []string{"completion", "bash"},
+			Name: "app",
+			Out:  &out,
+		})
+		is.Equal(t, 0, code)
+		is.True(t, strings.Contains(out.String(), "complete -o default -F _app_completion app"))
+	})
+
+	t.Run("returns the exit code, prints the error, and calls exit", func(t *testing.T) {
+		var errOut strings.Builder
+		var exitCode int
+		code := clir.RunWithOptions(clir.RunnerFunc(func(ctx clir.Context) error {
+			return clir.Exit(3, errors.New("oh no"))
+		}), clir.RunOptions{
+			Args: []string{},
+			Err:  &errOut,
+			Exit: func(code int) {
+				exitCode = code
+			},
+		})
+		is.Equal(t, 3, code)
+		is.Equal(t, 3, exitCode)
+		is.Equal(t, "Error: oh no\n", errOut.String())
+	})
+
+	t.Run("can print errors with a custom function", func(t *testing.T) {
+		var errOut strings.Builder
+		code := clir.RunWithOptions(clir.RunnerFunc(func(ctx clir.Context) error {
+			return errors.New("oh no")
+		}), clir.RunOptions{
+			Args: []string{},
+			Err:  &errOut,
+			PrintError: func(ctx clir.Context, err error) {
+				ctx.Errorfln("custom: %v", err)
+			},
+		})
+		is.Equal(t, 1, code)
+		is.Equal(t, "custom: oh no\n", errOut.String())
+	})
+
+	t.Run("uses the given context as the parent context", func(t *testing.T) {
+		type key struct{}
+		parent := context.WithValue(context.Background(), key{}, "value")
+
+		code := clir.RunWithOptions(clir.RunnerFunc(func(ctx clir.Context) error {
+			is.Equal(t, "value", ctx.Ctx.Value(key{}).(string))
+			return nil
+		}), clir.RunOptions{
+			Args: []string{},
+			Ctx:  parent,
+		})
+		is.Equal(t, 0, code)
+	})
+
+	t.Run("looks up environment variables with the given function", func(t *testing.T) {
+		var out strings.Builder
+		code := clir.RunWithOptions(clir.RunnerFunc(func(ctx clir.Context) error {
+			v, ok := ctx.LookupEnv("DANCE")
+			ctx.Println(v, ok)
+			return nil
+		}), clir.RunOptions{
+			Args: []string{},
+			Env: func(key string) (string, bool) {
+				return "salsa", key == "DANCE"
+			},
+			Out: &out,
+		})
+		is.Equal(t, 0, code)
+		is.Equal(t, "salsa true\n", out.String())
+	})
+
+	t.Run("uses the given working directory, or the process working directory by default", func(t *testing.T) {
+		wd, err := os.Getwd()
+		is.NotError(t, err)
+
+		var dirs []string
+		runner := clir.RunnerFunc(func(ctx clir.Context) error {
+			dirs = append(dirs, ctx.Dir)
+			return nil
+		})
+		clir.RunWithOptions(runner, clir.RunOptions{Args: []string{}, Dir: "/dance"})
+		clir.RunWithOptions(runner, clir.RunOptions{Args: []string{}})
+		is.Equal(t, 2, len(dirs))
+		is.Equal(t, "/dance", dirs[0])
+		is.Equal(t, wd, dirs[1])
+	})
+
+	t.Run("exits with 128 plus the signal number on cancellation by a signal", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("cannot send signals on Windows")
+		}
+
+		var errOut strings.Builder
+		code := clir.RunWithOptions(clir.RunnerFunc(func(ctx clir.Context) error {
+			p, err := os.FindProcess(os.Getpid())
+			if err != nil {
+				return err
+			}
+			if err := p.Signal(os.Interrupt); err != nil {
+				return err
+			}
+			<-ctx.Ctx.Done()
+			return ctx.Ctx.Err()
+		}), clir.RunOptions{
+			Args: []string{},
+			Err:  &errOut,
+		})
+		is.Equal(t, 130, code)
+		is.Equal(t, "Error: context canceled\n", errOut.String())
+	})
+}
+
+func TestWithValue(t *testing.T) {
+	type user struct {
+		Name string
+	}
+
+	t.Run("adds a value to get by type", func(t *testing.T) {
+		ctx := clir.WithValue(clir.Context{}, user{Name: "Gopher"})
+		ctx = clir.WithValue(ctx, 42)
+
+		u, ok := clir.Value[user](ctx)
+		is.True(t, ok)
+		is.Equal(t, "Gopher", u.Name)
+
+		n, ok := clir.Value[int](ctx)
+		is.True(t, ok)
+		is.Equal(t, 42, n)
+	})
+
+	t.Run("replaces an earlier value of the same type", func(t *testing.T) {
+		ctx := clir.WithValue(clir.Context{Ctx: context.Background()}, user{Name: "Gopher"})
+		ctx = clir.WithValue(ctx, user{Name: "Dancer"})
+
+		u, ok := clir.Value[user](ctx)
+		is.True(t, ok)
+		is.Equal(t, "Dancer", u.Name)
+	})
+
+	t.Run("returns the zero value if not found", func(t *testing.T) {
+		u, ok := clir.Value[user](clir.Context{})
+		is.True(t, !ok)
+		is.Equal(t, "", u.Name)
+
+		u, ok = clir.Value[user](clir.Context{Ctx: context.Background()})
+		is.True(t, !ok)
+		is.Equal(t, "", u.Name)
+	})
+
+	t.Run("passes values from middleware to routes", func(t *testing.T) {
+		r := clir.NewRouter()
+
+		r.Use(func(next clir.Runner) clir.Runner {
+			return clir.RunnerFunc(func(ctx clir.Context) error {
+				return next.Run(clir.WithValue(ctx, user{Name: "Gopher"}))
+			})
+		})
+
+		r.Branch("dance", func(r *clir.Router) {
+			r.RouteFunc("", func(ctx clir.Context) error {
+				u, _ := clir.Value[user](ctx)
+				ctx.Println(u.Name)
+				return nil
+			})
+		})
+
+		var b strings.Builder
+		err := r.Run(clir.Context{
+			Args: []string{"dance"},
+			Out:  &b,
+		})
+		is.NotError(t, err)
+		is.Equal(t, "Gopher\n", b.String())
+	})
+}
+
+func TestContext_Getenv(t *testing.T) {
+	t.Run("returns the environment variable or the empty string", func(t *testing.T) {
+		ctx := clir.Context{Env: func(key string) (string, bool) {
+			if key == "DANCE" {
+				return "salsa", true
+			}
+			return "", false
+		}}
+		is.Equal(t, "salsa", ctx.Getenv("DANCE"))
+		is.Equal(t, "", ctx.Getenv("PARTY"))
+	})
+}
+
+func TestContext_Abs(t *testing.T) {
+	t.Run("joins relative paths with the working directory", func(t *testing.T) {
+		ctx := clir.Context{Dir: filepath.FromSlash("/dance")}
+		is.Equal(t, filepath.FromSlash("/dance/salsa/steps.txt"), ctx.Abs(filepath.FromSlash("salsa/steps.txt")))
+	})
+
+	t.Run("returns absolute paths as is", func(t *testing.T) {
+		abs, err := filepath.Abs("steps.txt")
+		is.NotError(t, err)
+
+		ctx := clir.Context{Dir: filepath.FromSlash("/dance")}
+		is.Equal(t, abs, ctx.Abs(abs))
+	})
+
+	t.Run("uses the process working directory if the working directory is empty", func(t *testing.T) {
+		abs, err := filepath.Abs("steps.txt")
+		is.NotError(t, err)
+
+		is.Equal(t, abs, clir.Context{}.Abs("steps.txt"))
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"is 0 for no error", nil, 0},
+		{"is 1 for other errors", errors.New("oh no"), 1},
+		{"is the code of an exit error", clir.Exit(3, errors.New("oh no")), 3},
+		{"is the code of a wrapped exit error", fmt.Errorf("wrapped: %w", clir.Exit(4, nil)), 4},
+		{"is 2 for usage errors", &clir.UsageError{Err: errors.New("bad flag")}, 2},
+		{"is 2 for not found errors", &clir.NotFoundError{Arg: "stauts"}, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is.Equal(t, test.expected, clir.ExitCode(test.err))
+		})
+	}
+
+	t.Run("is 2 for route not found from a router", func(t *testing.T) {
+		r := clir.NewRouter()
+		err := r.Run(clir.Context{Args: []string{"dance"}})
+		is.Equal(t, 2, clir.ExitCode(err))
+	})
+}
+
+func TestExitError(t *testing.T) {
+	t.Run("unwraps to the error", func(t *testing.T) {
+		errOhNo := errors.New("oh no")
+		err := clir.Exit(3, errOhNo)
+		is.Error(t, errOhNo, err)
+		is.Equal(t, "oh no", err.Error())
+	})
+
+	t.Run("has a message with the exit code without an error", func(t *testing.T) {
+		err := clir.Exit(3, nil)
+		is.Equal(t, "exit code 3", err.Error())
+	})
+}
+
+func TestValidationError(t *testing.T) {
+	t.Run("has the message of a single problem", func(t *testing.T) {
+		err := &clir.ValidationError{Problems: []error{errors.New("missing required flag -name")}}
+		is.Equal(t, "missing required flag -name", err.Error())
+	})
+
+	t.Run("lists several problems", func(t *testing.T) {
+		errOhNo := errors.New("oh no")
+		err := &clir.ValidationError{Problems: []error{errors.New("missing required flag -name"), errOhNo}}
+		is.Equal(t, "invalid input:\n  - missing required flag -name\n  - oh no", err.Error())
+		is.Error(t, errOhNo, err)
+		is.Equal(t, 2, clir.ExitCode(err))
+	})
+}